@@ -1,62 +1,63 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gpbPiazza/httpfromtcp/internal/request"
 	"github.com/gpbPiazza/httpfromtcp/internal/response"
+	"github.com/gpbPiazza/httpfromtcp/internal/router"
 	"github.com/gpbPiazza/httpfromtcp/internal/server"
 )
 
+const shutdownTimeout = 30 * time.Second
+
 func main() {
-	handler := func(w *response.Writer, req *request.Request) {
-		if strings.HasPrefix(req.RequestLine.RequestTarget, "/httpbin") {
-			handlerProxyStream(w, req)
-			return
-		}
+	r := router.New(router.WithNotFoundHandler(handler404))
+	r.Get("/", handler200)
+	r.Get("/yourproblem", handler400)
+	r.Get("/myproblem", handler500)
+	r.Get("/video", handleVideo)
+	r.Get("/httpbin/*", handlerProxyStream)
+
+	server := server.New(
+		server.WithHandler(r.Handler()),
+		server.WithServerHeader("pinet"),
+		server.WithAccessLog(slog.New(server.NewAccessLogHandler(os.Stdout, server.AccessLogCombined))),
+	)
 
-		if strings.HasPrefix(req.RequestLine.RequestTarget, "/video") {
-			handleVideo(w, req)
-			return
-		}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-		if req.RequestLine.RequestTarget == "/yourproblem" {
-			handler400(w, req)
-			return
-		}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe(":42069")
+	}()
 
-		if req.RequestLine.RequestTarget == "/myproblem" {
-			handler500(w, req)
-			return
-		}
+	select {
+	case err := <-serveErr:
+		log.Fatalf("error on serve err: %s", err)
+	case <-sigChan:
+	}
 
-		if req.RequestLine.RequestTarget == "/" {
-			handler200(w, req)
-			return
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-		handler404(w, req)
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("error on shutdown, connections were cut off err: %s", err)
 		return
 	}
-
-	server := server.New(server.WithHandler(handler))
-
-	defer server.Close()
-
-	server.Listen("42069")
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
 	log.Println("Server gracefully stopped")
 }
 
@@ -67,24 +68,38 @@ func handleVideo(w *response.Writer, req *request.Request) {
 		handler500(w, req)
 		return
 	}
+	defer file.Close()
 
-	body, err := io.ReadAll(file)
+	info, err := file.Stat()
 	if err != nil {
-		log.Printf("err to readAll file err: %s", err)
+		log.Printf("err to stat file err: %s", err)
 		handler500(w, req)
 		return
 	}
 
-	w.WriteStatusLine(response.StatusOK)
-	h := response.DefaultHeaders(len(body))
-	h.Override("Content-Type", "video/mp4")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
+	if _, err = io.Copy(w, file); err != nil {
+		log.Printf("err to write file err: %s", err)
+	}
 }
 
 func handlerProxyStream(w *response.Writer, req *request.Request) {
-	requestTarget, _ := strings.CutPrefix(req.RequestLine.RequestTarget, "/httpbin")
-	resp, err := http.Get(fmt.Sprintf("%s/%s", "https://httpbin.org", requestTarget))
+	// the upstream host is fixed, the client only chooses the path and the query
+	upstream := url.URL{
+		Scheme:   "https",
+		Host:     "httpbin.org",
+		Path:     "/" + req.PathValue("*"),
+		RawQuery: req.URL.RawQuery,
+	}
+	proxyReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, upstream.String(), nil)
+	if err != nil {
+		log.Printf("error creating proxy request err: %s", err)
+		handler500(w, req)
+		return
+	}
+
+	resp, err := http.DefaultClient.Do(proxyReq)
 	if err != nil {
 		log.Printf("error proxing request err: %s", err)
 		handler500(w, req)
@@ -92,13 +107,8 @@ func handlerProxyStream(w *response.Writer, req *request.Request) {
 	}
 	defer resp.Body.Close()
 
-	w.WriteStatusLine(response.StatusOK)
-	h := response.DefaultHeaders(0)
-	h.Override("Transfer-Encoding", "chunked")
-	h.Add("Trailer", "X-Content-SHA256")
-	h.Add("Trailer", "X-Content-Length")
-	h.Delete("Content-Length")
-	w.WriteHeaders(h)
+	w.Header().Add("Trailer", "X-Content-SHA256")
+	w.Header().Add("Trailer", "X-Content-Length")
 
 	oneKbChunk := 1024
 	buf := make([]byte, oneKbChunk)
@@ -106,6 +116,13 @@ func handlerProxyStream(w *response.Writer, req *request.Request) {
 	for {
 		numBytesRead, err := resp.Body.Read(buf)
 		log.Printf("number of bytes readed from body - %d", numBytesRead)
+		if numBytesRead > 0 {
+			rawBody = append(rawBody, buf[:numBytesRead]...)
+			if _, err := w.Write(buf[:numBytesRead]); err != nil {
+				log.Printf("err Write err: %s", err)
+				break
+			}
+		}
 		if errors.Is(err, io.EOF) {
 			log.Print("end of file error - breaking while loop")
 			break
@@ -114,30 +131,14 @@ func handlerProxyStream(w *response.Writer, req *request.Request) {
 			log.Printf("err reading response body err: %s", err)
 			break
 		}
-
-		if numBytesRead > 0 {
-			rawBody = append(rawBody, buf[:numBytesRead]...)
-			if _, err = w.WriteChunkedBody(buf[:numBytesRead]); err != nil {
-				log.Printf("err WriteChunkedBody err: %s", err)
-				break
-			}
-		}
-	}
-
-	if _, err = w.WriteChunkedBodyDone(); err != nil {
-		log.Printf("err WriteChunkedBodyDone err: %s", err)
 	}
 
 	hashRawBody := sha256.Sum256(rawBody)
-	h.Add("X-Content-SHA256", fmt.Sprintf("%x", hashRawBody))
-	h.Add("X-Content-Length", fmt.Sprintf("%d", len(rawBody)))
-	if err = w.WriteTrailers(h); err != nil {
-		log.Printf("err WriteTrailers err: %s", err)
-	}
+	w.Header().Add("X-Content-SHA256", fmt.Sprintf("%x", hashRawBody))
+	w.Header().Add("X-Content-Length", fmt.Sprintf("%d", len(rawBody)))
 }
 
 func handler400(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusBadRequest)
 	body := []byte(`<html>
 <head>
 <title>400 Bad Request</title>
@@ -148,14 +149,12 @@ func handler400(w *response.Writer, _ *request.Request) {
 </body>
 </html>
 `)
-	h := response.DefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusBadRequest)
+	w.Write(body)
 }
 
 func handler500(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusInternalServerError)
 	body := []byte(`<html>
 <head>
 <title>500 Internal Server Error</title>
@@ -166,14 +165,12 @@ func handler500(w *response.Writer, _ *request.Request) {
 </body>
 </html>
 `)
-	h := response.DefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusInternalServerError)
+	w.Write(body)
 }
 
 func handler200(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusOK)
 	body := []byte(`<html>
 <head>
 <title>200 OK</title>
@@ -184,15 +181,12 @@ func handler200(w *response.Writer, _ *request.Request) {
 </body>
 </html>
 `)
-	h := response.DefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
-	return
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusOK)
+	w.Write(body)
 }
 
 func handler404(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusNotFound)
 	body := []byte(`<html>
 <head>
 <title>404 Not found</title>
@@ -203,9 +197,7 @@ func handler404(w *response.Writer, _ *request.Request) {
 </body>
 </html>
 `)
-	h := response.DefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
-	return
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusNotFound)
+	w.Write(body)
 }
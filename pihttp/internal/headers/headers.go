@@ -3,8 +3,9 @@ package headers
 import (
 	"bytes"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
-	"unicode"
 )
 
 const (
@@ -19,53 +20,93 @@ var (
 	crlfByte = []byte(crlf)
 )
 
-type Headers map[string]string
+// Headers are the field lines of a message, keyed by the lower case field name.
+// Each field line is kept as its own value, in the order they were added, so a repeated field
+// like Set-Cookie is not joined into a single value.
+type Headers map[string][]string
 
 func New() Headers {
 	return make(Headers)
 }
 
 // Get return Key value from Headers. Get is case insensitivity.
+// A key with many values is returned as a single comma separated value, see Values to get them apart.
 // Get will retunr false if the given key has no value.
 func (h Headers) Get(key string) (string, bool) {
-	val, ok := h[keyf(key)]
+	vals, ok := h[keyf(key)]
+	if !ok {
+		return "", false
+	}
+
+	return strings.Join(vals, ValSeparator), true
+}
 
-	return val, ok
+// Values returns the values of key in the order they were added, one per field line. Values is case insensitivity.
+func (h Headers) Values(key string) []string {
+	return h[keyf(key)]
 }
 
 func keyf(key string) string {
 	return strings.ToLower(strings.TrimSpace(key))
 }
 
+// Keys returns the keys of Headers in sorted order, the keys are lower case.
+func (h Headers) Keys() []string {
+	return slices.Sorted(maps.Keys(h))
+}
+
+// CanonicalKey returns the canonical form of key, the first letter and any letter following
+// a hyphen are upper case and the rest lower case, content-length is Content-Length.
+func CanonicalKey(key string) string {
+	canonical := []byte(strings.TrimSpace(key))
+
+	upper := true
+	for i, c := range canonical {
+		switch {
+		case upper && c >= 'a' && c <= 'z':
+			canonical[i] = c - ('a' - 'A')
+		case !upper && c >= 'A' && c <= 'Z':
+			canonical[i] = c + ('a' - 'A')
+		}
+		upper = c == '-'
+	}
+
+	return string(canonical)
+}
+
 // Add will insert a key value into header
 // Add will ensure case insensitivity.
-// If some key already has value add will concatenate the value separated by ",space".
+// If some key already has value the new value is kept after the existing ones, as another field line.
 func (h Headers) Add(key, val string) {
 	key, val = keyValf(key, val)
 
-	existingVal, ok := h[key]
-	if ok {
-		h[key] = fmt.Sprintf("%s%s%s", existingVal, ValSeparator, val)
-		return
-	}
+	h[key] = append(h[key], val)
+}
 
-	h[key] = val
+// Set replaces all values of key with val.
+func (h Headers) Set(key, val string) {
+	key, val = keyValf(key, val)
+
+	h[key] = []string{val}
 }
 
-func (h Headers) Delete(key string) {
+// Del removes all values of key.
+func (h Headers) Del(key string) {
 	delete(h, keyf(key))
 }
 
-// Override will ovewrite a key and value if the key already exist, if not will just add new key and value.
-func (h Headers) Override(key, val string) {
-	key, val = keyValf(key, val)
-
-	_, ok := h[key]
-	if ok {
-		delete(h, key)
+// HasToken reports if the comma separated list values of key contain token.
+// The comparison is case insensitivity, as the tokens of Connection and Transfer-Encoding are.
+func (h Headers) HasToken(key, token string) bool {
+	for _, val := range h.Values(key) {
+		for _, t := range strings.Split(val, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
 	}
 
-	h[key] = val
+	return false
 }
 
 func keyValf(key, val string) (string, string) {
@@ -86,6 +127,17 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		return len(crlfByte), true, nil
 	}
 
+	// a line starting with whitespace is the obsolete line folding, a continuation of the previous field line,
+	// see https://datatracker.ietf.org/doc/html/rfc9112#name-obsolete-line-folding
+	if strings.HasPrefix(headerText, space) || strings.HasPrefix(headerText, "\t") {
+		return 0, false, fmt.Errorf("malformed headers - obs-fold field line is not allowed - headers: %s", headerText)
+	}
+
+	// a CR or LF not followed by the other one is read as a line end by some parsers and not by others
+	if strings.ContainsAny(headerText, "\r\n") {
+		return 0, false, fmt.Errorf("malformed headers - bare CR or LF in field line - headers: %q", headerText)
+	}
+
 	sepIdx := strings.Index(headerText, keyValSeparator)
 	if sepIdx == -1 {
 		return 0, false, fmt.Errorf("malformed headers - not find header separator : - headers: %s", headerText)
@@ -99,6 +151,10 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		return 0, false, err
 	}
 
+	if err := ValidateValue(key, val); err != nil {
+		return 0, false, err
+	}
+
 	h.Add(key, val)
 
 	numBytesParsed := idx + 2
@@ -113,45 +169,59 @@ func (h Headers) valiadteKey(key string) error {
 		return fmt.Errorf("malformed headers key - got key ending with space - header key: %s", key)
 	}
 
-	hasInvalidChar := false
-	for _, r := range key {
-		if unicode.IsLetter(r) {
-			continue
-		}
-		if unicode.IsNumber(r) {
-			continue
-		}
+	if !IsToken(key) {
+		return fmt.Errorf("malformed headers - key header with not allowed char - header key: %s", key)
+	}
+
+	return nil
+}
+
+// InvalidValueError is returned when a field value has a char not allowed by RFC 9110.
+// A CR or LF written into a value would end the field line and let the rest of the value
+// be read as new field lines or as the body.
+type InvalidValueError struct {
+	Key   string
+	Value string
+	// Char is the first not allowed char of Value.
+	Char byte
+}
 
-		isSpecial := specialCharsAllowed[r]
-		if isSpecial {
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("malformed headers - value with not allowed char %q - header key: %s", e.Char, e.Key)
+}
+
+// ValidateValue returns an *InvalidValueError if val is not a valid field value, see
+// https://datatracker.ietf.org/doc/html/rfc9110#name-field-values
+//
+// The control chars, CR, LF, NUL and DEL included, are not allowed, HTAB is.
+// obs-text, the bytes from 0x80 to 0xFF, is allowed and kept as opaque data: clients send
+// UTF-8 text in values and none of these bytes can end a field line.
+func ValidateValue(key, val string) error {
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		if c == '\t' || (c >= ' ' && c != 0x7f) {
 			continue
 		}
 
-		hasInvalidChar = true
-	}
-
-	if hasInvalidChar {
-		return fmt.Errorf("malformed headers - key header with not allowed char - header key: %s", key)
+		return &InvalidValueError{Key: key, Value: val, Char: c}
 	}
 
 	return nil
 }
 
-var specialCharsAllowed = map[rune]bool{
-	'!':  true,
-	' ':  true,
-	'#':  true,
-	'$':  true,
-	'%':  true,
-	'&':  true,
-	'\'': true,
-	'*':  true,
-	'+':  true,
-	'-':  true,
-	'.':  true,
-	'^':  true,
-	'_':  true,
-	'`':  true,
-	'|':  true,
-	'~':  true,
+// Validate returns an error if any key or value of Headers can not be written as a field line.
+func (h Headers) Validate() error {
+	for _, key := range h.Keys() {
+		if err := h.valiadteKey(key); err != nil {
+			return err
+		}
+
+		for _, val := range h[key] {
+			if err := ValidateValue(key, val); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
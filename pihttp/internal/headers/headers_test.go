@@ -1,6 +1,7 @@
 package headers
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,7 +17,7 @@ func TestHeadersParse(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, "localhost:42069", headers["host"])
+		assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
 		assert.Equal(t, 23, n)
 		assert.False(t, done)
 	})
@@ -29,24 +30,24 @@ func TestHeadersParse(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, headers)
-		assert.Equal(t, "localhost:42069", headers["host"])
+		assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
 		assert.Equal(t, 22, n)
 		assert.False(t, done)
 	})
 
-	t.Run("valid headers with space in the middle of the key", func(t *testing.T) {
+	t.Run("invalid headers with space in the middle of the key", func(t *testing.T) {
 		headers := New()
-		data := []byte("       Content Length: 42069       \r\n\r\n")
+		data := []byte("Content Length: 42069       \r\n\r\n")
 
 		n, done, err := headers.Parse(data)
 
-		require.NoError(t, err)
-		require.False(t, done)
-		assert.Equal(t, 37, n)
-		assert.Equal(t, headers["content length"], "42069")
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - key header with not allowed char - header key: Content Length")
 	})
 
-	t.Run("valid headers same header appear must concat into the string", func(t *testing.T) {
+	t.Run("valid headers same header appear must keep each field line value", func(t *testing.T) {
 		headers := New()
 		data := []byte("e-o-gremio: ta forte\r\n")
 
@@ -55,7 +56,7 @@ func TestHeadersParse(t *testing.T) {
 		require.NoError(t, err)
 		require.False(t, done)
 		assert.Equal(t, 22, n)
-		assert.Equal(t, headers["e-o-gremio"], "ta forte")
+		assert.Equal(t, []string{"ta forte"}, headers.Values("e-o-gremio"))
 
 		data = []byte("e-o-gremio: é os guri\r\n")
 
@@ -64,7 +65,7 @@ func TestHeadersParse(t *testing.T) {
 		require.NoError(t, err)
 		require.False(t, done)
 		assert.Equal(t, 24, n)
-		assert.Equal(t, headers["e-o-gremio"], "ta forte, é os guri")
+		assert.Equal(t, []string{"ta forte", "é os guri"}, headers.Values("e-o-gremio"))
 
 		data = []byte("e-o-gremio: gremiooo\r\n")
 
@@ -73,19 +74,23 @@ func TestHeadersParse(t *testing.T) {
 		require.NoError(t, err)
 		require.False(t, done)
 		assert.Equal(t, 22, n)
-		assert.Equal(t, headers["e-o-gremio"], "ta forte, é os guri, gremiooo")
+		assert.Equal(t, []string{"ta forte", "é os guri", "gremiooo"}, headers.Values("e-o-gremio"))
+
+		val, ok := headers.Get("E-O-Gremio")
+		require.True(t, ok)
+		assert.Equal(t, "ta forte, é os guri, gremiooo", val)
 	})
 
 	t.Run("always set key header to lower case", func(t *testing.T) {
 		headers := New()
-		data := []byte("       VAMO GREMIO-PORRA1!: 42069       \r\n\r\n")
+		data := []byte("VAMO-GREMIO-PORRA1!: 42069       \r\n\r\n")
 
 		n, done, err := headers.Parse(data)
 
 		require.NoError(t, err)
 		require.False(t, done)
-		assert.Equal(t, 42, n)
-		assert.Equal(t, headers["vamo gremio-porra1!"], "42069")
+		assert.Equal(t, 35, n)
+		assert.Equal(t, []string{"42069"}, headers.Values("vamo-gremio-porra1!"))
 	})
 
 	t.Run("return true when data is a only a crlf - the next line will be the body so parse headers is done", func(t *testing.T) {
@@ -101,7 +106,7 @@ func TestHeadersParse(t *testing.T) {
 
 	t.Run("invalid headers with space between key name and :", func(t *testing.T) {
 		headers := New()
-		data := []byte("       Host : localhost:42069       \r\n\r\n")
+		data := []byte("Host : localhost:42069       \r\n\r\n")
 
 		n, done, err := headers.Parse(data)
 
@@ -113,7 +118,7 @@ func TestHeadersParse(t *testing.T) {
 
 	t.Run("invalid headers with no : separator", func(t *testing.T) {
 		headers := New()
-		data := []byte("       Content-Length 42069       \r\n\r\n")
+		data := []byte("Content-Length 42069       \r\n\r\n")
 
 		n, done, err := headers.Parse(data)
 
@@ -123,6 +128,82 @@ func TestHeadersParse(t *testing.T) {
 		assert.ErrorContains(t, err, "malformed headers - not find header separator : - headers:")
 	})
 
+	t.Run("invalid obs-fold field line starting with space", func(t *testing.T) {
+		headers := New()
+		data := []byte("       Host: localhost:42069\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - obs-fold field line is not allowed")
+	})
+
+	t.Run("invalid obs-fold field line starting with tab", func(t *testing.T) {
+		headers := New()
+		data := []byte("\tlocalhost:42069\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - obs-fold field line is not allowed")
+	})
+
+	t.Run("invalid bare LF in field line", func(t *testing.T) {
+		headers := New()
+		data := []byte("Host: localhost:42069\nTransfer-Encoding: chunked\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - bare CR or LF in field line")
+	})
+
+	t.Run("invalid bare CR in field line", func(t *testing.T) {
+		headers := New()
+		data := []byte("Host: localhost:42069\rTransfer-Encoding: chunked\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - bare CR or LF in field line")
+	})
+
+	t.Run("invalid headers value with control char", func(t *testing.T) {
+		headers := New()
+		data := []byte("X-Forwarded-For: 10.0.0.1\x00evil\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - value with not allowed char '\\x00' - header key: X-Forwarded-For")
+
+		var valErr *InvalidValueError
+		require.True(t, errors.As(err, &valErr))
+		assert.Equal(t, byte(0), valErr.Char)
+	})
+
+	t.Run("valid headers value with tab and obs-text", func(t *testing.T) {
+		headers := New()
+		data := []byte("X-Name: gr\têmio\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.NoError(t, err)
+		assert.False(t, done)
+		assert.Equal(t, len(data)-2, n)
+		assert.Equal(t, []string{"gr\têmio"}, headers.Values("x-name"))
+	})
+
 	t.Run("invalid headers key char", func(t *testing.T) {
 		headers := New()
 		data := []byte("H©st: localhost:42069\r\n\r\n")
@@ -135,6 +216,42 @@ func TestHeadersParse(t *testing.T) {
 		assert.ErrorContains(t, err, "malformed headers - key header with not allowed char - header key: H©st")
 	})
 
+	t.Run("invalid headers key with non ASCII letter", func(t *testing.T) {
+		headers := New()
+		data := []byte("Grêmio: tricolor\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - key header with not allowed char - header key: Grêmio")
+	})
+
+	t.Run("invalid headers key with non ASCII digit", func(t *testing.T) {
+		headers := New()
+		data := []byte("X-٤٢: 42\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - key header with not allowed char - header key: X-٤٢")
+	})
+
+	t.Run("invalid empty headers key", func(t *testing.T) {
+		headers := New()
+		data := []byte(": 42\r\n\r\n")
+
+		n, done, err := headers.Parse(data)
+
+		require.Error(t, err)
+		assert.Equal(t, 0, n)
+		assert.False(t, done)
+		assert.ErrorContains(t, err, "malformed headers - key header with not allowed char - header key: ")
+	})
+
 	t.Run("invalid headers key char wiht emoji text", func(t *testing.T) {
 		headers := New()
 		data := []byte("┐( ͡◉ ͜ʖ ͡◉)┌: ligma?\r\n\r\n")
@@ -160,3 +277,148 @@ func TestHeadersParse(t *testing.T) {
 	})
 
 }
+
+func TestHeadersHasToken(t *testing.T) {
+	t.Run("find token in a comma separated list ignoring case", func(t *testing.T) {
+		headers := New()
+		headers.Add("Connection", "keep-alive, Close")
+
+		assert.True(t, headers.HasToken("connection", "close"))
+		assert.True(t, headers.HasToken("CONNECTION", "keep-alive"))
+	})
+
+	t.Run("return false when key is not present or token does not match", func(t *testing.T) {
+		headers := New()
+		headers.Add("Connection", "closed")
+
+		assert.False(t, headers.HasToken("Connection", "close"))
+		assert.False(t, headers.HasToken("Transfer-Encoding", "chunked"))
+	})
+}
+
+func TestCanonicalKey(t *testing.T) {
+	testCases := []struct {
+		key      string
+		expected string
+	}{
+		{key: "content-length", expected: "Content-Length"},
+		{key: "CONTENT-TYPE", expected: "Content-Type"},
+		{key: "x-content-sha256", expected: "X-Content-Sha256"},
+		{key: "www-authenticate", expected: "Www-Authenticate"},
+		{key: "host", expected: "Host"},
+		{key: "  date ", expected: "Date"},
+		{key: "-dash--key-", expected: "-Dash--Key-"},
+		{key: "", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.expected, CanonicalKey(tc.key))
+		})
+	}
+}
+
+func TestHeadersKeys(t *testing.T) {
+	headers := New()
+	headers.Add("Server", "pinet")
+	headers.Add("Content-Type", "text/plain")
+	headers.Add("date", "Sun, 06 Nov 1994 08:49:37 GMT")
+	headers.Add("Accept", "*/*")
+
+	assert.Equal(t, []string{"accept", "content-type", "date", "server"}, headers.Keys())
+}
+
+func TestHeadersValues(t *testing.T) {
+	t.Run("add keeps each value and set replaces all of them", func(t *testing.T) {
+		headers := New()
+		headers.Add("Set-Cookie", "a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT")
+		headers.Add("set-cookie", "b=2")
+
+		assert.Equal(t, []string{"a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT", "b=2"}, headers.Values("SET-COOKIE"))
+
+		headers.Set("Set-Cookie", "c=3")
+
+		assert.Equal(t, []string{"c=3"}, headers.Values("Set-Cookie"))
+	})
+
+	t.Run("del removes all values", func(t *testing.T) {
+		headers := New()
+		headers.Add("Vary", "Accept")
+		headers.Add("Vary", "Origin")
+
+		headers.Del("vary")
+
+		assert.Empty(t, headers.Values("Vary"))
+		_, ok := headers.Get("Vary")
+		assert.False(t, ok)
+	})
+
+	t.Run("has token looks into every field line", func(t *testing.T) {
+		headers := New()
+		headers.Add("Transfer-Encoding", "gzip")
+		headers.Add("Transfer-Encoding", "chunked")
+
+		assert.True(t, headers.HasToken("Transfer-Encoding", "chunked"))
+	})
+}
+
+func TestValidateValue(t *testing.T) {
+	testCases := []struct {
+		name    string
+		val     string
+		invalid bool
+		char    byte
+	}{
+		{name: "visible ascii", val: "text/html; charset=utf-8"},
+		{name: "space and tab", val: "a b\tc"},
+		{name: "obs-text", val: "caf\xe9 é"},
+		{name: "empty", val: ""},
+		{name: "crlf injection", val: "/home\r\nSet-Cookie: admin=true", invalid: true, char: '\r'},
+		{name: "bare lf", val: "/home\nSet-Cookie: admin=true", invalid: true, char: '\n'},
+		{name: "nul", val: "a\x00", invalid: true, char: 0},
+		{name: "del", val: "a\x7f", invalid: true, char: 0x7f},
+		{name: "escape", val: "\x1b[31m", invalid: true, char: 0x1b},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateValue("Location", tc.val)
+
+			if !tc.invalid {
+				assert.NoError(t, err)
+				return
+			}
+
+			var valErr *InvalidValueError
+			require.True(t, errors.As(err, &valErr))
+			assert.Equal(t, "Location", valErr.Key)
+			assert.Equal(t, tc.val, valErr.Value)
+			assert.Equal(t, tc.char, valErr.Char)
+		})
+	}
+}
+
+func TestHeadersValidate(t *testing.T) {
+	t.Run("valid headers", func(t *testing.T) {
+		headers := New()
+		headers.Add("Location", "/home")
+		headers.Add("Set-Cookie", "a=1")
+
+		assert.NoError(t, headers.Validate())
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		headers := New()
+		headers.Add("Location", "/home\r\nSet-Cookie: admin=true")
+
+		var valErr *InvalidValueError
+		assert.True(t, errors.As(headers.Validate(), &valErr))
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		headers := New()
+		headers.Add("X-Evil\r\nSet-Cookie", "admin=true")
+
+		assert.ErrorContains(t, headers.Validate(), "malformed headers - key header with not allowed char")
+	})
+}
@@ -0,0 +1,43 @@
+package headers
+
+// tchar is the lookup table of the chars allowed in a token, field names and methods are tokens.
+// Only ASCII chars are allowed, see https://datatracker.ietf.org/doc/html/rfc9110#name-tokens
+//
+//	tchar = "!" / "#" / "$" / "%" / "&" / "'" / "*" / "+" / "-" / "." /
+//	        "^" / "_" / "`" / "|" / "~" / DIGIT / ALPHA
+var tchar = func() [256]bool {
+	var table [256]bool
+
+	for c := '0'; c <= '9'; c++ {
+		table[c] = true
+	}
+	for c := 'a'; c <= 'z'; c++ {
+		table[c] = true
+		table[c-('a'-'A')] = true
+	}
+	for _, c := range "!#$%&'*+-.^_`|~" {
+		table[c] = true
+	}
+
+	return table
+}()
+
+// IsTokenChar reports if c is a tchar.
+func IsTokenChar(c byte) bool {
+	return tchar[c]
+}
+
+// IsToken reports if s is a token, one or more tchar.
+func IsToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !tchar[s[i]] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package headers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTokenChar(t *testing.T) {
+	// every tchar of https://datatracker.ietf.org/doc/html/rfc9110#name-tokens written by hand
+	const tchars = "!#$%&'*+-.^_`|~" +
+		"0123456789" +
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz"
+
+	testCases := make([]struct {
+		c        byte
+		expected bool
+	}, 256)
+	for i := range testCases {
+		testCases[i].c = byte(i)
+		testCases[i].expected = strings.IndexByte(tchars, byte(i)) != -1
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%#02x", tc.c), func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsTokenChar(tc.c), "char %q", tc.c)
+		})
+	}
+}
+
+func TestIsToken(t *testing.T) {
+	testCases := []struct {
+		s        string
+		expected bool
+	}{
+		{s: "Content-Length", expected: true},
+		{s: "GET", expected: true},
+		{s: "M-SEARCH", expected: true},
+		{s: "x!#$%&'*+-.^_`|~9", expected: true},
+		{s: "", expected: false},
+		{s: "Content Length", expected: false},
+		{s: "Host:", expected: false},
+		{s: "Grêmio", expected: false},
+		{s: "X-٤٢", expected: false},
+		{s: "a\x00", expected: false},
+		{s: "\"quoted\"", expected: false},
+		{s: "(comment)", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.s, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsToken(tc.s))
+		})
+	}
+}
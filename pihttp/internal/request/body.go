@@ -0,0 +1,357 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxDrainBytes is how much of a body not read by the handler is discarded on Close
+	// before giving up on the connection.
+	maxDrainBytes = 256 << 10
+)
+
+var (
+	ErrBodyReadAfterClose = errors.New("error: read on closed request body")
+	ErrBodyNotDrained     = errors.New("error: request body too large to be drained")
+)
+
+// body is the Request.Body, it reads the message body from the connection as the handler asks for it.
+// body is bounded by the Content-Length or by the chunked framing, the bytes after it are left
+// in the Reader buffer to the next request.
+type body struct {
+	req  *Request
+	conn *Reader
+
+	chunked    bool
+	chunkState chunkState
+	// remaining is the number of bytes left of the Content-Length or of the current chunk data.
+	remaining uint64
+
+	// total is the number of body bytes decoded so far.
+	total int64
+	// trailerBytes and trailerCount are the size and the number of field lines of the trailer section,
+	// bounded by the same limits as the header section.
+	trailerBytes int
+	trailerCount int
+
+	done   bool
+	closed bool
+	err    error
+}
+
+func (r *Request) newBody(conn *Reader) (*body, error) {
+	b := &body{
+		req:  r,
+		conn: conn,
+	}
+
+	chunked, err := r.transferEncoding()
+	if err != nil {
+		return nil, err
+	}
+
+	if chunked {
+		b.chunked = true
+		r.ContentLength = -1
+		return b, nil
+	}
+
+	contentLenght, ok, err := r.contentLength()
+	if err != nil {
+		return nil, err
+	}
+
+	// without Content-Length and Transfer-Encoding a request has no body,
+	// see https://datatracker.ietf.org/doc/html/rfc9112#name-message-body-length
+	if !ok || contentLenght == 0 {
+		b.done = true
+		r.state = requestStateCompled
+		return b, nil
+	}
+
+	if r.opts.maxBodyBytes > 0 && contentLenght > r.opts.maxBodyBytes {
+		return nil, newParseError(KindBodyTooLarge, ErrBodyTooLarge)
+	}
+
+	b.remaining = uint64(contentLenght)
+	r.ContentLength = contentLenght
+
+	return b, nil
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyReadAfterClose
+	}
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	for !b.done {
+		if len(p) == 0 {
+			return 0, nil
+		}
+
+		n, err := b.decode(p)
+		b.total += int64(n)
+		if err == nil && b.req.opts.maxBodyBytes > 0 && b.total > b.req.opts.maxBodyBytes {
+			err = newParseError(KindBodyTooLarge, ErrBodyTooLarge)
+		}
+		if err != nil {
+			b.err = err
+			return n, err
+		}
+
+		if n > 0 {
+			return n, nil
+		}
+
+		if b.done {
+			break
+		}
+
+		if err := b.fill(); err != nil {
+			b.err = err
+			return 0, err
+		}
+	}
+
+	b.req.state = requestStateCompled
+
+	return 0, io.EOF
+}
+
+// Close discards what is left of the body so the next request on the connection can be read.
+// Close returns ErrBodyNotDrained if more than maxDrainBytes are left, in that case the connection
+// must not be reused.
+func (b *body) Close() error {
+	if b.closed {
+		return nil
+	}
+	defer func() { b.closed = true }()
+
+	if b.done {
+		return nil
+	}
+
+	_, err := io.Copy(io.Discard, io.LimitReader(b, maxDrainBytes))
+	if err != nil {
+		return err
+	}
+
+	if !b.done {
+		return ErrBodyNotDrained
+	}
+
+	return nil
+}
+
+// fill reads more bytes from the connection.
+func (b *body) fill() error {
+	// a chunk-size line or the trailer section without crlf can not grow forever.
+	if b.chunked && b.chunkState != chunkStateData && b.trailerBytes+len(b.conn.buf) > b.req.opts.maxHeaderBytes {
+		return newParseError(KindHeadersTooLarge, ErrHeadersTooLarge)
+	}
+
+	err := b.conn.fill()
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return newTimeoutError(err)
+}
+
+// decode moves the body bytes from the connection buffer into p.
+// decode returns 0 without error when the buffer has not enough bytes to make progress.
+func (b *body) decode(p []byte) (int, error) {
+	if !b.chunked {
+		n := min(uint64(len(p)), uint64(len(b.conn.buf)), b.remaining)
+
+		copy(p, b.conn.buf[:n])
+		b.conn.buf = b.conn.buf[n:]
+		b.remaining -= n
+		b.done = b.remaining == 0
+
+		return int(n), nil
+	}
+
+	for !b.done {
+		numBytesParsed, n, err := b.decodeChunked(p)
+		b.conn.buf = b.conn.buf[numBytesParsed:]
+
+		if err != nil || n > 0 || numBytesParsed == 0 {
+			return n, err
+		}
+	}
+
+	return 0, nil
+}
+
+// A chunked body is sent in the following format, see https://datatracker.ietf.org/doc/html/rfc9112#name-chunked-transfer-coding
+//
+// <chunk-size in hex>[;chunk-ext]\r\n
+// <chunk-data of chunk-size bytes>\r\n
+// ... repeat ...
+// 0[;chunk-ext]\r\n
+// <trailer field lines>\r\n
+// \r\n
+
+type chunkState int
+
+const (
+	chunkStateSize = iota
+	chunkStateData
+	chunkStateDataEnd
+	chunkStateTrailers
+)
+
+// transferEncoding reports if the body is chunked. Only chunked is implemented and it must be the final
+// coding, otherwise the body length is unknown, see https://datatracker.ietf.org/doc/html/rfc9112#name-message-body-length
+//
+// A request with both Transfer-Encoding and Content-Length is rejected, a proxy in front of the server
+// could use one and the server the other, disagreeing where the request ends. That is how requests are smuggled.
+func (r *Request) transferEncoding() (bool, error) {
+	transferEncoding, ok := r.Headers.Get("Transfer-Encoding")
+	if !ok {
+		return false, nil
+	}
+
+	if !r.ProtoAtLeast(1, 1) {
+		// HTTP/1.0 has no transfer codings, the body length must be sent on Content-Length
+		return false, newParseError(
+			KindLengthRequired,
+			errors.New("error: transfer encoding is not allowed on HTTP/1.0 - content length is required"),
+		)
+	}
+
+	if _, ok := r.Headers.Get("Content-Length"); ok {
+		return false, newParseError(
+			KindInvalidFraming,
+			errors.New("error: transfer encoding and content length are both present"),
+		)
+	}
+
+	codings := strings.Split(transferEncoding, ",")
+	for i, coding := range codings {
+		isLast := i == len(codings)-1
+		isChunked := strings.EqualFold(strings.TrimSpace(coding), "chunked")
+
+		if isChunked != isLast {
+			return false, newParseError(
+				KindInvalidFraming,
+				fmt.Errorf("error: chunked must be the final transfer coding - transfer encoding: %s", transferEncoding),
+			)
+		}
+	}
+
+	if len(codings) > 1 {
+		return false, newParseError(
+			KindTransferCodingNotImplemented,
+			fmt.Errorf("error: transfer coding not implemented - transfer encoding: %s", transferEncoding),
+		)
+	}
+
+	return true, nil
+}
+
+// decodeChunked decodes one piece of the chunked body per call: a chunk-size line, chunk data,
+// the crlf after the chunk data or a trailer field line.
+// decodeChunked returns the number of bytes parsed from the connection buffer and the number of chunk data bytes copied into p.
+// The trailer fields are set into the request Trailers.
+func (b *body) decodeChunked(p []byte) (int, int, error) {
+	switch b.chunkState {
+	case chunkStateSize:
+		n, err := b.parseChunkSize()
+		return n, 0, err
+	case chunkStateData:
+		n := min(uint64(len(p)), uint64(len(b.conn.buf)), b.remaining)
+
+		copy(p, b.conn.buf[:n])
+		b.remaining -= n
+		if b.remaining == 0 {
+			b.chunkState = chunkStateDataEnd
+		}
+
+		return int(n), int(n), nil
+	case chunkStateDataEnd:
+		if len(b.conn.buf) < len(crlfByte) {
+			return 0, 0, nil
+		}
+
+		if !bytes.HasPrefix(b.conn.buf, crlfByte) {
+			return 0, 0, newParseError(KindMalformedBody, errors.New("error: chunk data is not followed by crlf"))
+		}
+
+		b.chunkState = chunkStateSize
+		return len(crlfByte), 0, nil
+	case chunkStateTrailers:
+		n, done, err := b.req.Trailers.Parse(b.conn.buf)
+		if err != nil {
+			return 0, 0, newParseError(KindMalformedBody, err)
+		}
+		if err := b.countTrailerLine(n, done); err != nil {
+			return 0, 0, err
+		}
+		b.done = done
+		return n, 0, nil
+	default:
+		return 0, 0, errors.New("unknow chunk state")
+	}
+}
+
+func (b *body) countTrailerLine(numBytesParsed int, done bool) error {
+	if numBytesParsed == 0 {
+		return nil
+	}
+
+	b.trailerBytes += numBytesParsed
+	if b.trailerBytes > b.req.opts.maxHeaderBytes {
+		return newParseError(KindHeadersTooLarge, ErrHeadersTooLarge)
+	}
+
+	if !done {
+		b.trailerCount++
+	}
+	if b.trailerCount > b.req.opts.maxHeaderCount {
+		return newParseError(KindHeadersTooLarge, ErrHeadersTooLarge)
+	}
+
+	return nil
+}
+
+func (b *body) parseChunkSize() (int, error) {
+	idx := bytes.Index(b.conn.buf, crlfByte)
+	if idx == -1 {
+		return 0, nil
+	}
+
+	sizeLine := string(b.conn.buf[:idx])
+	// chunk extensions are allowed but we do not understand any of them, so they are ignored.
+	if extIdx := strings.Index(sizeLine, ";"); extIdx != -1 {
+		sizeLine = sizeLine[:extIdx]
+	}
+	sizeLine = strings.TrimRight(sizeLine, " \t")
+
+	size, err := strconv.ParseUint(sizeLine, 16, 63)
+	if err != nil {
+		return 0, newParseError(
+			KindMalformedBody,
+			fmt.Errorf("error: chunk size is not a valid hex number - chunk size: %s", sizeLine),
+		)
+	}
+
+	if size == 0 {
+		b.chunkState = chunkStateTrailers
+	} else {
+		b.chunkState = chunkStateData
+		b.remaining = size
+	}
+
+	return idx + len(crlfByte), nil
+}
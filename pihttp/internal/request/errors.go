@@ -0,0 +1,88 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrRequestLineTooLong = errors.New("error: request line too long")
+	ErrHeadersTooLarge    = errors.New("error: request header fields too large")
+	ErrBodyTooLarge       = errors.New("error: request body too large")
+	ErrRequestTimeout     = errors.New("error: request not received in time")
+)
+
+// ErrorKind is the machine readable reason of a ParseError.
+type ErrorKind string
+
+const (
+	KindMalformedRequestLine ErrorKind = "malformed_request_line"
+	KindMalformedHeaders     ErrorKind = "malformed_headers"
+	KindMalformedBody        ErrorKind = "malformed_body"
+	KindMethodNotImplemented ErrorKind = "method_not_implemented"
+	KindVersionNotSupported  ErrorKind = "version_not_supported"
+	KindLengthRequired       ErrorKind = "length_required"
+	KindRequestLineTooLong   ErrorKind = "request_line_too_long"
+	KindHeadersTooLarge      ErrorKind = "headers_too_large"
+	KindBodyTooLarge         ErrorKind = "body_too_large"
+	KindExpectationFailed    ErrorKind = "expectation_failed"
+	// KindInvalidFraming is an ambiguous or invalid Content-Length and Transfer-Encoding.
+	KindInvalidFraming               ErrorKind = "invalid_framing"
+	KindTransferCodingNotImplemented ErrorKind = "transfer_coding_not_implemented"
+	// KindRequestTimeout is a read deadline of the connection expired in the middle of the request.
+	KindRequestTimeout ErrorKind = "request_timeout"
+)
+
+// kindStatusCode maps each ErrorKind to the status code the server must answer.
+var kindStatusCode = map[ErrorKind]int{
+	KindMalformedRequestLine:         400, // Bad Request
+	KindMalformedHeaders:             400, // Bad Request
+	KindMalformedBody:                400, // Bad Request
+	KindMethodNotImplemented:         501, // Not Implemented
+	KindVersionNotSupported:          505, // HTTP Version Not Supported
+	KindLengthRequired:               411, // Length Required
+	KindRequestLineTooLong:           414, // URI Too Long
+	KindHeadersTooLarge:              431, // Request Header Fields Too Large
+	KindBodyTooLarge:                 413, // Content Too Large
+	KindExpectationFailed:            417, // Expectation Failed
+	KindInvalidFraming:               400, // Bad Request
+	KindTransferCodingNotImplemented: 501, // Not Implemented
+	KindRequestTimeout:               408, // Request Timeout
+}
+
+// ParseError is returned when the request sent by the client is not valid.
+// Errors that are not a ParseError, like io errors, mean the connection is broken
+// and there is no one to answer.
+type ParseError struct {
+	// StatusCode is the status code to answer the request with.
+	StatusCode int
+	Kind       ErrorKind
+	Err        error
+}
+
+func newParseError(kind ErrorKind, err error) *ParseError {
+	return &ParseError{
+		StatusCode: kindStatusCode[kind],
+		Kind:       kind,
+		Err:        err,
+	}
+}
+
+// newTimeoutError returns a KindRequestTimeout ParseError when err is a read deadline exceeded,
+// otherwise err is returned as is.
+func newTimeoutError(err error) error {
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+
+	return newParseError(KindRequestTimeout, fmt.Errorf("%w: %w", ErrRequestTimeout, err))
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
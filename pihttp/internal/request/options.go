@@ -0,0 +1,95 @@
+package request
+
+const (
+	DefaultMaxRequestLineBytes = 8 << 10
+	DefaultMaxHeaderBytes      = 1 << 20
+	DefaultMaxHeaderCount      = 100
+)
+
+type options struct {
+	maxRequestLineBytes int
+	maxHeaderBytes      int
+	maxHeaderCount      int
+	maxBodyBytes        int64
+}
+
+func defaultOptions() options {
+	return options{
+		maxRequestLineBytes: DefaultMaxRequestLineBytes,
+		maxHeaderBytes:      DefaultMaxHeaderBytes,
+		maxHeaderCount:      DefaultMaxHeaderCount,
+		maxBodyBytes:        0,
+	}
+}
+
+type Option interface {
+	apply(*options)
+}
+
+// WithMaxRequestLineBytes limits the length of the request line, crlf included.
+// A request line longer than that fails with ErrRequestLineTooLong.
+func WithMaxRequestLineBytes(n int) Option {
+	return &optionWithMaxRequestLineBytes{
+		n: n,
+	}
+}
+
+type optionWithMaxRequestLineBytes struct {
+	n int
+}
+
+func (o *optionWithMaxRequestLineBytes) apply(opts *options) {
+	opts.maxRequestLineBytes = o.n
+}
+
+// WithMaxHeaderBytes limits the size of the header section, the request line is not included.
+// The same limit applies to the trailer section of a chunked body.
+// A header section bigger than that fails with ErrHeadersTooLarge.
+func WithMaxHeaderBytes(n int) Option {
+	return &optionWithMaxHeaderBytes{
+		n: n,
+	}
+}
+
+type optionWithMaxHeaderBytes struct {
+	n int
+}
+
+func (o *optionWithMaxHeaderBytes) apply(opts *options) {
+	opts.maxHeaderBytes = o.n
+}
+
+// WithMaxHeaderCount limits the number of field lines in the header section.
+// The trailer section of a chunked body is limited to the same number of field lines.
+// More field lines than that fails with ErrHeadersTooLarge.
+func WithMaxHeaderCount(n int) Option {
+	return &optionWithMaxHeaderCount{
+		n: n,
+	}
+}
+
+type optionWithMaxHeaderCount struct {
+	n int
+}
+
+func (o *optionWithMaxHeaderCount) apply(opts *options) {
+	opts.maxHeaderCount = o.n
+}
+
+// WithMaxBodyBytes limits the size of the body, for chunked bodies it is the size of the decoded data.
+// A body bigger than that fails with ErrBodyTooLarge, as soon as the Content-Length is known or
+// when reading the chunk that crosses the limit.
+// A zero or negative n means the body size is not limited, that is the default.
+func WithMaxBodyBytes(n int64) Option {
+	return &optionWithMaxBodyBytes{
+		n: n,
+	}
+}
+
+type optionWithMaxBodyBytes struct {
+	n int64
+}
+
+func (o *optionWithMaxBodyBytes) apply(opts *options) {
+	opts.maxBodyBytes = o.n
+}
@@ -0,0 +1,157 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Reader reads the requests sent on a connection one after another.
+// A client can pipeline requests, sending many of them before reading any response,
+// so the bytes read past the end of one request are kept to the next ReadRequest.
+type Reader struct {
+	src  io.Reader
+	opts options
+	// buf has the bytes read from src and not parsed yet, they belong to the body of the
+	// current request or to the next requests.
+	buf     []byte
+	readBuf []byte
+	// body is the body of the last request read, it must be consumed before the next request.
+	body *body
+}
+
+// NewReader returns a Reader of the requests sent on src.
+// See the Option functions to limit the size of the requests.
+func NewReader(src io.Reader, opts ...Option) *Reader {
+	option := defaultOptions()
+
+	for _, opt := range opts {
+		opt.apply(&option)
+	}
+
+	return &Reader{
+		src:     src,
+		opts:    option,
+		readBuf: make([]byte, parserBufferSize),
+	}
+}
+
+// ReadRequest reads and parses the request line and the headers of the next request.
+// ReadRequest returns as soon as the headers are parsed, the body is read only when Request.Body is read.
+// What is left of the previous request body is discarded first, see Request.Body Close.
+//
+// ReadRequest returns io.EOF if src ends before any byte of the request is read,
+// that is how a client closes a persistent connection between requests.
+func (rr *Reader) ReadRequest() (*Request, error) {
+	if err := rr.discardBody(); err != nil {
+		return nil, err
+	}
+
+	request := newRequest(rr.opts)
+
+	for {
+		rr.skipEmptyLines(request)
+
+		numBytesParsed, err := request.parse(rr.buf)
+		if err != nil {
+			return nil, err
+		}
+		rr.buf = rr.buf[numBytesParsed:]
+
+		if request.isHeadersParsed() {
+			break
+		}
+
+		if err := request.checkPendingLimits(len(rr.buf)); err != nil {
+			return nil, err
+		}
+
+		err = rr.fill()
+		isStarted := request.state != requestStateInitialized || len(rr.buf) > 0
+		if errors.Is(err, io.EOF) {
+			if !isStarted {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf(
+				"incomplete request, in state: %d, bytes not parsed on EOF: %d: %w",
+				request.state,
+				len(rr.buf),
+				io.ErrUnexpectedEOF,
+			)
+		}
+
+		if err != nil && isStarted {
+			// the client started the request and stopped sending it
+			return nil, newTimeoutError(err)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := request.newBody(rr)
+	if err != nil {
+		return nil, err
+	}
+	request.Body = body
+	rr.body = body
+
+	return request, nil
+}
+
+// WaitForRequest blocks until the first byte of the next request is read, or returns right away if it
+// was already read. What is left of the previous request body is discarded first.
+// Use it to tell an idle connection apart from a connection sending a request.
+//
+// WaitForRequest returns io.EOF if src ends before any byte is read.
+func (rr *Reader) WaitForRequest() error {
+	if err := rr.discardBody(); err != nil {
+		return err
+	}
+
+	for len(rr.buf) == 0 {
+		if err := rr.fill(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discardBody closes the body of the last request read, see Request.Body Close.
+func (rr *Reader) discardBody() error {
+	if rr.body == nil {
+		return nil
+	}
+
+	if err := rr.body.Close(); err != nil {
+		return err
+	}
+	rr.body = nil
+
+	return nil
+}
+
+// skipEmptyLines drops the empty lines before the request line, some clients send an extra
+// crlf after a request body, see https://datatracker.ietf.org/doc/html/rfc9112#name-message-parsing
+func (rr *Reader) skipEmptyLines(request *Request) {
+	if request.state != requestStateInitialized {
+		return
+	}
+
+	for len(rr.buf) > 0 && (rr.buf[0] == '\r' || rr.buf[0] == '\n') {
+		rr.buf = rr.buf[1:]
+	}
+}
+
+// fill reads more bytes from src into buf.
+func (rr *Reader) fill() error {
+	n, err := rr.src.Read(rr.readBuf)
+	rr.buf = append(rr.buf, rr.readBuf[:n]...)
+	if n > 0 {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,216 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderReadRequest(t *testing.T) {
+	t.Run("pipelined requests are read one after another", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "GET /first HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"\r\n" +
+				"POST /second HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Content-Length: 5\r\n" +
+				"\r\n" +
+				"hello" +
+				"POST /third HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\n" +
+				"world\r\n" +
+				"0\r\n" +
+				"\r\n",
+			numBytesPerRead: 1080,
+		}
+		requestReader := NewReader(reader)
+
+		r, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/first", r.URL.Path)
+		assert.Empty(t, readBody(t, r))
+
+		r, err = requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/second", r.URL.Path)
+		assert.Equal(t, "hello", readBody(t, r))
+
+		r, err = requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/third", r.URL.Path)
+		assert.Equal(t, "world", readBody(t, r))
+
+		r, err = requestReader.ReadRequest()
+		require.Nil(t, r)
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("body not read is discarded before the next request", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /first HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Content-Length: 13\r\n" +
+				"\r\n" +
+				"hello world!\n" +
+				"GET /second HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"\r\n",
+			numBytesPerRead: 7,
+		}
+		requestReader := NewReader(reader)
+
+		r, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/first", r.URL.Path)
+
+		r, err = requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/second", r.URL.Path)
+	})
+
+	t.Run("empty lines before the request line are ignored", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /first HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Content-Length: 2\r\n" +
+				"\r\n" +
+				"hi\r\n" +
+				"GET /second HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"\r\n" +
+				"\n",
+			numBytesPerRead: 5,
+		}
+		requestReader := NewReader(reader)
+
+		r, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "hi", readBody(t, r))
+
+		r, err = requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/second", r.URL.Path)
+
+		r, err = requestReader.ReadRequest()
+		require.Nil(t, r)
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("reader ends in the middle of the next request", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "GET /first HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"\r\n" +
+				"GET /sec",
+			numBytesPerRead: 1080,
+		}
+		requestReader := NewReader(reader)
+
+		_, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+
+		r, err := requestReader.ReadRequest()
+		require.Nil(t, r)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
+func TestReaderTimeout(t *testing.T) {
+	t.Run("deadline exceeded in the middle of the headers", func(t *testing.T) {
+		reader := &deadlineReader{data: "GET / HTTP/1.1\r\nHost: local"}
+		requestReader := NewReader(reader)
+
+		r, err := requestReader.ReadRequest()
+
+		require.Nil(t, r)
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, KindRequestTimeout, parseErr.Kind)
+		assert.Equal(t, 408, parseErr.StatusCode)
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+
+	t.Run("deadline exceeded before the request is not a parse error", func(t *testing.T) {
+		requestReader := NewReader(&deadlineReader{})
+
+		r, err := requestReader.ReadRequest()
+
+		require.Nil(t, r)
+		var parseErr *ParseError
+		assert.False(t, errors.As(err, &parseErr))
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+
+	t.Run("deadline exceeded in the middle of the body", func(t *testing.T) {
+		reader := &deadlineReader{data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 10\r\n\r\nhello"}
+		requestReader := NewReader(reader)
+
+		r, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+		_, err = io.ReadAll(r.Body)
+
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, KindRequestTimeout, parseErr.Kind)
+	})
+}
+
+func TestReaderWaitForRequest(t *testing.T) {
+	t.Run("waits for the first byte of the request", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 1,
+		}
+		requestReader := NewReader(reader)
+
+		err := requestReader.WaitForRequest()
+		require.NoError(t, err)
+		assert.Equal(t, 1, reader.pos)
+
+		r, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/first", r.URL.Path)
+
+		err = requestReader.WaitForRequest()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("body is discarded before waiting", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "POST /first HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello",
+			numBytesPerRead: 1080,
+		}
+		requestReader := NewReader(reader)
+
+		_, err := requestReader.ReadRequest()
+		require.NoError(t, err)
+
+		err = requestReader.WaitForRequest()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+// deadlineReader reads data and then fails as a connection with its read deadline exceeded.
+type deadlineReader struct {
+	data string
+	pos  int
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+
+	return n, nil
+}
@@ -2,12 +2,12 @@ package request
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/gpbPiazza/httpfromtcp/internal/headers"
 )
@@ -18,9 +18,12 @@ const (
 	crlf = "\r\n"
 	// single space = SP
 	space            = " "
-	parserBufferSize = 8
+	parserBufferSize = 4096
 
-	httpVSuported = "HTTP/1.1"
+	expectContinue = "100-continue"
+
+	httpName           = "HTTP"
+	httpMajorVSuported = 1
 
 	MethodGet     = "GET"
 	MethodHead    = "HEAD"
@@ -56,11 +59,58 @@ var (
 
 type Request struct {
 	RequestLine RequestLine
-	Headers     headers.Headers
-	Body        []byte
+	// URL is the parsed RequestLine.RequestTarget.
+	URL     *URL
+	Headers headers.Headers
+	// Body streams the message body from the connection, it is never nil.
+	// Body returns io.EOF once Content-Length bytes or the last chunk are read.
+	Body io.ReadCloser
+	// ContentLength is the body length, -1 when it is unknown because the body is chunked.
+	ContentLength int64
+	// Trailers are the fields sent after a chunked body, they are only set after Body returns io.EOF.
+	Trailers headers.Headers
+	// PathParams are the path parameters matched by a router pattern, like id on /users/{id}.
+	PathParams map[string]string
+	// RemoteAddr is the address of the client, set by the server.
+	RemoteAddr string
+	// ConnID identifies the connection the request was read from, set by the server.
+	ConnID string
+
+	ctx context.Context
 
 	state             requestState
-	bodyContentLenght *int
+	bodyContentLenght *int64
+	opts              options
+	headerBytes       int
+	headerCount       int
+}
+
+// Context returns the request context, it is never nil. The server cancels it when the client closes
+// the connection, the response write times out or fails, or the server shuts down.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
+// WithContext returns a shallow copy of r with its context set to ctx, use it to add values to the request context.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("request: nil context")
+	}
+
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+
+	return r2
+}
+
+// PathValue returns the value of the path parameter name, or an empty string if it was not matched.
+func (r *Request) PathValue(name string) string {
+	return r.PathParams[name]
 }
 
 type requestState int
@@ -73,63 +123,40 @@ const (
 )
 
 type RequestLine struct {
+	// HttpVersion is the <digit>.<digit> part of the version, 1.1 or 1.0.
 	HttpVersion   string
+	ProtoMajor    int
+	ProtoMinor    int
 	RequestTarget string
 	Method        string
 }
 
-func ParseFromReader(reader io.Reader) (*Request, error) {
-	request := &Request{
-		state:   requestStateInitialized,
-		Headers: headers.New(),
-		Body:    make([]byte, 0),
-	}
-
-	var numBytesReaded int
-	buff := make([]byte, parserBufferSize)
-	for !request.isFullParsed() {
-		if numBytesReaded >= len(buff) {
-			newBuff := make([]byte, 2*len(buff))
-			_ = copy(newBuff, buff)
-			buff = newBuff
-		}
-
-		numBytesRead, err := reader.Read(buff[numBytesReaded:])
-		if errors.Is(err, io.EOF) {
-			if request.state != requestStateCompled {
-				return nil, fmt.Errorf(
-					"incomplete request, in state: %d, read n bytes on EOF: %d",
-					request.state,
-					numBytesRead,
-				)
-			}
-			break
-		}
-
-		if err != nil {
-			return nil, err
-		}
-
-		numBytesReaded += numBytesRead
-		numBytesParsed, err := request.parse(buff[:numBytesReaded])
-		if err != nil {
-			return nil, err
-		}
+// ParseFromReader reads and parses the request line and the headers of one request from reader.
+// ParseFromReader returns as soon as the headers are parsed, the body is read from reader
+// only when Request.Body is read.
+// ParseFromReader returns io.EOF if the reader ends before any byte of the request is read,
+// that is how a client closes a persistent connection between requests.
+// See the Option functions to limit the size of the request.
+//
+// Bytes read past the end of the request are lost, use a Reader to read many requests
+// from the same connection.
+func ParseFromReader(reader io.Reader, opts ...Option) (*Request, error) {
+	return NewReader(reader, opts...).ReadRequest()
+}
 
-		newBuff := make([]byte, len(buff))
-		buffDst := buff[numBytesParsed:]
-		copy(newBuff, buffDst)
-		buff = newBuff
-		numBytesReaded -= numBytesParsed
+func newRequest(opts options) *Request {
+	return &Request{
+		state:    requestStateInitialized,
+		Headers:  headers.New(),
+		Trailers: headers.New(),
+		opts:     opts,
 	}
-
-	return request, nil
 }
 
 func (r *Request) parse(data []byte) (int, error) {
 	totalBytesParsed := 0
 
-	for r.state != requestStateCompled {
+	for !r.isHeadersParsed() {
 		toBeParsed := data[totalBytesParsed:]
 
 		numBytesParsed, err := r.parseChunk(toBeParsed)
@@ -164,22 +191,22 @@ func (r *Request) parseChunk(data []byte) (int, error) {
 	case requestStateParsingHeaders:
 		n, done, err := r.Headers.Parse(data)
 		if err != nil {
+			return 0, newParseError(KindMalformedHeaders, err)
+		}
+		if err := r.countHeaderLine(n, done); err != nil {
 			return 0, err
 		}
 		if done {
+			if err := r.validateHost(); err != nil {
+				return 0, err
+			}
+			if err := r.validateExpect(); err != nil {
+				return 0, err
+			}
 			r.state = requestStateParsingBody
 		}
 		return n, nil
-	case requestStateParsingBody:
-		numBytesParsed, done, err := r.parseBody(data)
-		if err != nil {
-			return numBytesParsed, err
-		}
-		if done {
-			r.state = requestStateCompled
-		}
-		return numBytesParsed, nil
-	case requestStateCompled:
+	case requestStateParsingBody, requestStateCompled:
 		return 0, errors.New("error: trying to parse data in a done state")
 	default:
 		return 0, errors.New("unknow request state")
@@ -194,13 +221,21 @@ func (r *Request) parseRequestLine(data []byte) (int, error) {
 		return 0, nil
 	}
 
+	if idx+len(crlfByte) > r.opts.maxRequestLineBytes {
+		return 0, newParseError(KindRequestLineTooLong, ErrRequestLineTooLong)
+	}
+
+	if bytes.ContainsAny(data[:idx], "\r\n") {
+		return 0, newParseError(KindMalformedRequestLine, errors.New("request line with bare CR or LF"))
+	}
+
 	requestText := string(data[:idx])
 	requestPerLine := strings.Split(requestText, crlf)
 	requestLine := requestPerLine[0]
 	requestLinePerSpace := strings.Split(requestLine, space)
 
 	if len(requestLinePerSpace) != 3 {
-		return 0, errors.New("request line has not 3 parts format")
+		return 0, newParseError(KindMalformedRequestLine, errors.New("request line has not 3 parts format"))
 	}
 
 	method := requestLinePerSpace[0]
@@ -217,11 +252,19 @@ func (r *Request) parseRequestLine(data []byte) (int, error) {
 		return 0, err
 	}
 
+	url, err := parseRequestTarget(method, target)
+	if err != nil {
+		return 0, newParseError(KindMalformedRequestLine, err)
+	}
+
 	httpV := httpVSplited[1]
 
 	r.RequestLine.HttpVersion = httpV
+	r.RequestLine.ProtoMajor = int(httpV[0] - '0')
+	r.RequestLine.ProtoMinor = int(httpV[2] - '0')
 	r.RequestLine.RequestTarget = target
 	r.RequestLine.Method = method
+	r.URL = url
 
 	numBytesParsed := len(requestLine) + len(crlfByte)
 
@@ -229,8 +272,18 @@ func (r *Request) parseRequestLine(data []byte) (int, error) {
 }
 
 func (r *Request) validateMethod(method string) error {
+	if !headers.IsToken(method) {
+		return newParseError(
+			KindMalformedRequestLine,
+			fmt.Errorf("request method malformed method is not a token - method got %q", method),
+		)
+	}
+
 	if !isAllCaps(method) {
-		return errors.New("request method malformed method is not in all captal letter")
+		return newParseError(
+			KindMalformedRequestLine,
+			errors.New("request method malformed method is not in all captal letter"),
+		)
 	}
 
 	for _, mappedM := range AllMethods {
@@ -239,74 +292,167 @@ func (r *Request) validateMethod(method string) error {
 		}
 	}
 
-	return fmt.Errorf(
+	return newParseError(KindMethodNotImplemented, fmt.Errorf(
 		"request method unsported - method got %s - see AllMethods variable to suported methods",
 		method,
-	)
+	))
 }
 
+// isAllCaps reports if s has only the ASCII upper case letters.
 func isAllCaps(s string) bool {
-	for _, r := range s {
-		if !unicode.IsLetter(r) {
-			return false
-		}
-		if !unicode.IsUpper(r) {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
 			return false
 		}
 	}
 	return true
 }
 
+// validateHTTPVersion accepts HTTP/1.0 and HTTP/1.1, see https://datatracker.ietf.org/doc/html/rfc9112#name-http-version
 func (r *Request) validateHTTPVersion(httpV string, httpVSplited []string) error {
-	if len(httpVSplited) != 2 {
-		return errors.New("malformed http version expected <HTTP-NAME>/<digit>.<digit>")
+	if len(httpVSplited) != 2 || httpVSplited[0] != httpName || !isVersionNumber(httpVSplited[1]) {
+		return newParseError(
+			KindMalformedRequestLine,
+			errors.New("malformed http version expected <HTTP-NAME>/<digit>.<digit>"),
+		)
 	}
 
-	if httpV != httpVSuported {
-		return fmt.Errorf(
-			"unsoported http version - the httpVersion is %s and only httpVersion suported is %s",
+	if int(httpVSplited[1][0]-'0') != httpMajorVSuported {
+		return newParseError(KindVersionNotSupported, fmt.Errorf(
+			"unsoported http version - the httpVersion is %s and only httpVersion suported are HTTP/1.0 and HTTP/1.1",
 			httpV,
-			httpVSuported,
-		)
+		))
 	}
 
 	return nil
 }
 
-func (r *Request) isFullParsed() bool {
-	return r.state == requestStateCompled
+func isVersionNumber(v string) bool {
+	return len(v) == 3 && isDigit(v[0]) && v[1] == '.' && isDigit(v[2])
 }
 
-func (r *Request) parseBody(data []byte) (int, bool, error) {
-	contentLenght, ok, err := r.contentLength()
-	if err != nil {
-		return 0, false, err
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// ProtoAtLeast reports if the request HTTP version is at least major.minor.
+func (r *Request) ProtoAtLeast(major, minor int) bool {
+	return r.RequestLine.ProtoMajor > major ||
+		r.RequestLine.ProtoMajor == major && r.RequestLine.ProtoMinor >= minor
+}
+
+// validateHost checks the Host header is sent, it is only optional on HTTP/1.0,
+// see https://datatracker.ietf.org/doc/html/rfc9112#name-request-target
+func (r *Request) validateHost() error {
+	// servers and proxies picking different Host lines would route the request to different hosts,
+	// see https://datatracker.ietf.org/doc/html/rfc9112#name-request-target
+	if len(r.Headers.Values("Host")) > 1 {
+		return newParseError(KindMalformedHeaders, errors.New("error: host header sent more than once"))
 	}
 
-	// does the chunk that is at the end of the body?
-	idx := bytes.Index(data, []byte("\n"))
-	if !ok && len(data) == 0 || !ok && idx == 0 {
-		return 0, true, nil
+	if host, ok := r.Headers.Get("Host"); ok && !isHost(host) {
+		return newParseError(KindMalformedHeaders, fmt.Errorf("error: host header value is not valid - host: %s", host))
 	}
 
-	if !ok {
-		return 0, false, errors.New("error: content length header is required")
+	if !r.ProtoAtLeast(1, 1) {
+		return nil
+	}
+
+	if len(r.Headers.Values("Host")) != 1 {
+		return newParseError(KindMalformedHeaders, errors.New("error: host header is required on HTTP/1.1"))
+	}
+
+	return nil
+}
+
+// isHost reports if s is uri-host [ ":" port ], see https://datatracker.ietf.org/doc/html/rfc9110#name-host-and-authority
+// An empty value is allowed, it is sent when the target URI has no authority.
+func isHost(s string) bool {
+	if strings.ContainsAny(s, " \t,/?#@\\") {
+		return false
+	}
+
+	host := s
+	if idx := strings.LastIndex(s, ":"); idx != -1 && !strings.HasSuffix(s, "]") {
+		host = s[:idx]
+		for _, r := range s[idx+1:] {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+	}
+
+	// an IPv6 host is in brackets, [::1], its colons are not the port separator
+	if strings.HasPrefix(host, "[") {
+		return len(host) > 2 && strings.HasSuffix(host, "]") && !strings.ContainsAny(host[1:len(host)-1], "[]")
+	}
+
+	return !strings.ContainsAny(host, ":[]")
+}
+
+// validateExpect checks the only expectation known, 100-continue, see https://datatracker.ietf.org/doc/html/rfc9110#name-expect
+func (r *Request) validateExpect() error {
+	expect, ok := r.Headers.Get("Expect")
+	if !ok || strings.EqualFold(expect, expectContinue) {
+		return nil
+	}
+
+	return newParseError(KindExpectationFailed, fmt.Errorf("error: unknown expectation - expect: %s", expect))
+}
+
+// ExpectContinue reports if the client waits for a 100 Continue interim response before sending the body.
+// HTTP/1.0 clients do not know 100 Continue so the expectation is ignored for them.
+func (r *Request) ExpectContinue() bool {
+	expect, ok := r.Headers.Get("Expect")
+	return ok && strings.EqualFold(expect, expectContinue) && r.ProtoAtLeast(1, 1)
+}
+
+// checkPendingLimits checks the bytes read but not parsed yet, they are part of a line
+// still incomplete, so the limits can be exceeded before the line ends.
+func (r *Request) checkPendingLimits(numBytesPending int) error {
+	switch r.state {
+	case requestStateInitialized:
+		if numBytesPending > r.opts.maxRequestLineBytes {
+			return newParseError(KindRequestLineTooLong, ErrRequestLineTooLong)
+		}
+	case requestStateParsingHeaders:
+		if r.headerBytes+numBytesPending > r.opts.maxHeaderBytes {
+			return newParseError(KindHeadersTooLarge, ErrHeadersTooLarge)
+		}
 	}
 
-	r.Body = append(r.Body, data...)
+	return nil
+}
+
+func (r *Request) countHeaderLine(numBytesParsed int, done bool) error {
+	if numBytesParsed == 0 {
+		return nil
+	}
 
-	if len(r.Body) > contentLenght {
-		return 0, false, errors.New("error: content length informed is less than body length")
+	r.headerBytes += numBytesParsed
+	if r.headerBytes > r.opts.maxHeaderBytes {
+		return newParseError(KindHeadersTooLarge, ErrHeadersTooLarge)
 	}
 
-	if len(r.Body) == contentLenght {
-		return len(data), true, nil
+	if !done {
+		r.headerCount++
+	}
+	if r.headerCount > r.opts.maxHeaderCount {
+		return newParseError(KindHeadersTooLarge, ErrHeadersTooLarge)
 	}
 
-	return len(data), false, nil
+	return nil
+}
+
+// isHeadersParsed reports if the request line and the headers are parsed,
+// from that point on the data belongs to the body.
+func (r *Request) isHeadersParsed() bool {
+	return r.state >= requestStateParsingBody
 }
 
-func (r *Request) contentLength() (int, bool, error) {
+// contentLength returns the Content-Length value. The same field sent many times, or as a list,
+// is only accepted when all values are the same, see https://datatracker.ietf.org/doc/html/rfc9110#name-content-length
+func (r *Request) contentLength() (int64, bool, error) {
 	if r.bodyContentLenght != nil {
 		return *r.bodyContentLenght, true, nil
 	}
@@ -316,9 +462,25 @@ func (r *Request) contentLength() (int, bool, error) {
 		return 0, false, nil
 	}
 
-	contentLenght, err := strconv.Atoi(contentLenghtStr)
-	if err != nil {
-		return 0, false, errors.New("error: content length value is not an int")
+	contentLenght := int64(-1)
+	for _, val := range strings.Split(contentLenghtStr, ",") {
+		val = strings.TrimSpace(val)
+		if val == "" || strings.TrimLeft(val, "0123456789") != "" {
+			return 0, false, newParseError(KindInvalidFraming, errors.New("error: content length value is not an int"))
+		}
+
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, false, newParseError(KindInvalidFraming, errors.New("error: content length value is too big"))
+		}
+
+		if contentLenght != -1 && n != contentLenght {
+			return 0, false, newParseError(
+				KindInvalidFraming,
+				fmt.Errorf("error: conflicting content length values - content length: %s", contentLenghtStr),
+			)
+		}
+		contentLenght = n
 	}
 
 	r.bodyContentLenght = &contentLenght
@@ -1,7 +1,10 @@
 package request
 
 import (
+	"context"
+	"errors"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,7 +28,9 @@ func TestParseFromReader(t *testing.T) {
 		assert.Equal(t, "GET", r.RequestLine.Method)
 		assert.Equal(t, "/", r.RequestLine.RequestTarget)
 		assert.Equal(t, "1.1", r.RequestLine.HttpVersion)
-		assert.Empty(t, r.Body)
+		assert.Equal(t, 1, r.RequestLine.ProtoMajor)
+		assert.Equal(t, 1, r.RequestLine.ProtoMinor)
+		assert.Empty(t, readBody(t, r))
 	})
 
 	t.Run("Good request with \n at the end of the body", func(t *testing.T) {
@@ -40,7 +45,7 @@ func TestParseFromReader(t *testing.T) {
 		assert.Equal(t, "GET", r.RequestLine.Method)
 		assert.Equal(t, "/httpbin/stream/100", r.RequestLine.RequestTarget)
 		assert.Equal(t, "1.1", r.RequestLine.HttpVersion)
-		assert.Empty(t, r.Body)
+		assert.Empty(t, readBody(t, r))
 	})
 
 	t.Run("Good request line with request target", func(t *testing.T) {
@@ -58,7 +63,7 @@ func TestParseFromReader(t *testing.T) {
 		assert.Equal(t, "GET", r.RequestLine.Method)
 		assert.Equal(t, "/coffee", r.RequestLine.RequestTarget)
 		assert.Equal(t, "1.1", r.RequestLine.HttpVersion)
-		assert.Empty(t, r.Body)
+		assert.Empty(t, readBody(t, r))
 	})
 
 	t.Run("without http method", func(t *testing.T) {
@@ -113,6 +118,21 @@ func TestParseFromReader(t *testing.T) {
 		require.ErrorContains(t, err, "request method malformed method is not in all captal letter")
 	})
 
+	t.Run("method with non ASCII upper case letter", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GÉT / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		require.ErrorContains(t, err, "request method malformed method is not a token")
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, KindMalformedRequestLine, parseErr.Kind)
+	})
+
 	t.Run("method not mapped", func(t *testing.T) {
 		reader := &chunkReader{
 			data:            "PIZZA / HTTP/1.1\r\nHost: localhost:42069\r\nUser-Agent: curl/7.81.0\r\nAccept: */*\r\n\r\n",
@@ -136,7 +156,7 @@ func TestParseFromReader(t *testing.T) {
 
 		require.Nil(t, r)
 		require.Error(t, err)
-		require.ErrorContains(t, err, "unsoported http version - the httpVersion is HTTP/2.0 and only httpVersion suported is HTTP/1.1")
+		require.ErrorContains(t, err, "unsoported http version - the httpVersion is HTTP/2.0 and only httpVersion suported are HTTP/1.0 and HTTP/1.1")
 	})
 
 	t.Run("http version malformed", func(t *testing.T) {
@@ -152,6 +172,165 @@ func TestParseFromReader(t *testing.T) {
 		require.ErrorContains(t, err, "malformed http version expected <HTTP-NAME>/<digit>.<digit>")
 	})
 
+	t.Run("http version without minor digit", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET / HTTP/1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		require.ErrorContains(t, err, "malformed http version expected <HTTP-NAME>/<digit>.<digit>")
+	})
+
+	t.Run("http version with other name than HTTP", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET / HTTPS/1.1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		require.ErrorContains(t, err, "malformed http version expected <HTTP-NAME>/<digit>.<digit>")
+	})
+
+	t.Run("http version 1.0 without host", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET / HTTP/1.0\r\nUser-Agent: ApacheBench/2.3\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, "1.0", r.RequestLine.HttpVersion)
+		assert.Equal(t, 1, r.RequestLine.ProtoMajor)
+		assert.Equal(t, 0, r.RequestLine.ProtoMinor)
+		assert.False(t, r.ProtoAtLeast(1, 1))
+	})
+
+	t.Run("host sent more than once or with an invalid value", func(t *testing.T) {
+		testCases := []struct {
+			hostLines string
+			errMsg    string
+		}{
+			{"Host: localhost:42069\r\nHost: evil.com\r\n", "error: host header sent more than once"},
+			{"Host: localhost, evil.com\r\n", "error: host header value is not valid - host: localhost, evil.com"},
+			{"Host: local host\r\n", "error: host header value is not valid - host: local host"},
+			{"Host: localhost/admin\r\n", "error: host header value is not valid - host: localhost/admin"},
+			{"Host: user@localhost\r\n", "error: host header value is not valid - host: user@localhost"},
+			{"Host: localhost:http\r\n", "error: host header value is not valid - host: localhost:http"},
+			{"Host: ::1:443\r\n", "error: host header value is not valid - host: ::1:443"},
+		}
+
+		for _, tc := range testCases {
+			for _, version := range []string{"1.1", "1.0"} {
+				reader := &chunkReader{
+					data:            "GET / HTTP/" + version + "\r\n" + tc.hostLines + "\r\n",
+					numBytesPerRead: 8,
+				}
+
+				r, err := ParseFromReader(reader)
+
+				require.Nil(t, r)
+				require.ErrorContains(t, err, tc.errMsg)
+				var parseErr *ParseError
+				require.ErrorAs(t, err, &parseErr)
+				assert.Equal(t, 400, parseErr.StatusCode)
+			}
+		}
+	})
+
+	t.Run("host with a valid value", func(t *testing.T) {
+		for _, host := range []string{"", "localhost", "localhost:42069", "127.0.0.1:8080", "[::1]", "[::1]:443"} {
+			reader := &chunkReader{
+				data:            "GET / HTTP/1.1\r\nHost: " + host + "\r\n\r\n",
+				numBytesPerRead: 8,
+			}
+
+			r, err := ParseFromReader(reader)
+
+			require.NoError(t, err, host)
+			hostVal, _ := r.Headers.Get("Host")
+			assert.Equal(t, host, hostVal)
+		}
+	})
+
+	t.Run("http version 1.1 without host", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET / HTTP/1.1\r\nUser-Agent: curl/7.81.0\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		require.ErrorContains(t, err, "error: host header is required on HTTP/1.1")
+	})
+
+	t.Run("http version 1.0 with transfer encoding", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.0\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\n" +
+				"hello\r\n" +
+				"0\r\n" +
+				"\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, KindLengthRequired, parseErr.Kind)
+	})
+
+	t.Run("expect 100-continue", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "POST /upload HTTP/1.1\r\nHost: localhost:42069\r\nExpect: 100-Continue\r\nContent-Length: 5\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		assert.True(t, r.ExpectContinue())
+		assert.Equal(t, int64(5), r.ContentLength)
+	})
+
+	t.Run("expect 100-continue is ignored on http version 1.0", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "POST /upload HTTP/1.0\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		assert.False(t, r.ExpectContinue())
+	})
+
+	t.Run("unknown expectation", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "POST /upload HTTP/1.1\r\nHost: localhost:42069\r\nExpect: 200-ok\r\nContent-Length: 5\r\n\r\n",
+			numBytesPerRead: 8,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, KindExpectationFailed, parseErr.Kind)
+		assert.Equal(t, 417, parseErr.StatusCode)
+	})
+
 	t.Run("Standard Headers", func(t *testing.T) {
 		reader := &chunkReader{
 			data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nUser-Agent: curl/7.81.0\r\nAccept: */*\r\n\r\n",
@@ -162,9 +341,9 @@ func TestParseFromReader(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, r)
-		assert.Equal(t, "localhost:42069", r.Headers["host"])
-		assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-		assert.Equal(t, "*/*", r.Headers["accept"])
+		assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+		assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+		assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
 	})
 
 	t.Run("With Duplicated Headers", func(t *testing.T) {
@@ -173,7 +352,7 @@ func TestParseFromReader(t *testing.T) {
 		reader := &chunkReader{
 			data: "GET / HTTP/1.1\r\n" +
 				"Host: localhost:42069\r\n" +
-				" gremio: vamo0\r\n" +
+				"gremio: vamo0\r\n" +
 				"User-Agent: curl/7.81.0\r\n" +
 				"Accept: */*\r\n" +
 				"gremio: vamo1\r\n" +
@@ -187,10 +366,12 @@ func TestParseFromReader(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, r)
-		assert.Equal(t, "localhost:42069", r.Headers["host"])
-		assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-		assert.Equal(t, "*/*", r.Headers["accept"])
-		assert.Equal(t, "vamo0, vamo1, vamo2, vamo3", r.Headers["gremio"])
+		assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+		assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+		assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
+		assert.Equal(t, []string{"vamo0", "vamo1", "vamo2", "vamo3"}, r.Headers.Values("gremio"))
+		gremio, _ := r.Headers.Get("gremio")
+		assert.Equal(t, "vamo0, vamo1, vamo2, vamo3", gremio)
 	})
 
 	t.Run("Standard Body", func(t *testing.T) {
@@ -207,7 +388,7 @@ func TestParseFromReader(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, r)
-		assert.Equal(t, "hello world!\n", string(r.Body))
+		assert.Equal(t, "hello world!\n", readBody(t, r))
 	})
 
 	t.Run("request with json body", func(t *testing.T) {
@@ -227,7 +408,7 @@ func TestParseFromReader(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, r)
-		assert.Equal(t, "{\"flavor\":\"dark mode\"}", string(r.Body))
+		assert.Equal(t, "{\"flavor\":\"dark mode\"}", readBody(t, r))
 	})
 
 	t.Run("Empty body reported zero length", func(t *testing.T) {
@@ -243,7 +424,7 @@ func TestParseFromReader(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, r)
-		assert.Empty(t, r.Body)
+		assert.Empty(t, readBody(t, r))
 	})
 
 	t.Run("Empty body and NOT reported zero length", func(t *testing.T) {
@@ -258,10 +439,10 @@ func TestParseFromReader(t *testing.T) {
 
 		require.NoError(t, err)
 		require.NotNil(t, r)
-		assert.Empty(t, r.Body)
+		assert.Empty(t, readBody(t, r))
 	})
 
-	t.Run("Body is bigger than reported content length", func(t *testing.T) {
+	t.Run("Body stops at the reported content length", func(t *testing.T) {
 		reader := &chunkReader{
 			data: "POST /submit HTTP/1.1\r\n" +
 				"Host: localhost:42069\r\n" +
@@ -273,9 +454,9 @@ func TestParseFromReader(t *testing.T) {
 
 		r, err := ParseFromReader(reader)
 
-		require.Error(t, err)
-		require.Nil(t, r)
-		assert.ErrorContains(t, err, "error: content length informed is less than body length")
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, "pa", readBody(t, r))
 	})
 
 	t.Run("content lenght is not an int", func(t *testing.T) {
@@ -296,6 +477,7 @@ func TestParseFromReader(t *testing.T) {
 	})
 
 	t.Run("No content length reported and has body", func(t *testing.T) {
+		// without Content-Length and Transfer-Encoding the bytes after the headers belong to the next request
 		reader := &chunkReader{
 			data: "POST /submit HTTP/1.1\r\n" +
 				"Host: localhost:42069\r\n" +
@@ -306,12 +488,603 @@ func TestParseFromReader(t *testing.T) {
 
 		r, err := ParseFromReader(reader)
 
-		require.Error(t, err)
-		require.ErrorContains(t, err, "error: content length header is required")
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		assert.Empty(t, readBody(t, r))
+	})
+
+	t.Run("chunked body", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\n" +
+				"hello\r\n" +
+				"7;name=value\r\n" +
+				" world!\r\n" +
+				"0\r\n" +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, "hello world!", readBody(t, r))
+		assert.Empty(t, r.Trailers)
+	})
+
+	t.Run("chunked body with trailers", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"Trailer: X-Content-Length\r\n" +
+				"\r\n" +
+				"1a\r\n" +
+				"abcdefghijklmnopqrstuvwxyz\r\n" +
+				"0\r\n" +
+				"X-Content-Length: 26\r\n" +
+				"\r\n",
+			numBytesPerRead: 7,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, "abcdefghijklmnopqrstuvwxyz", readBody(t, r))
+		assert.Equal(t, []string{"26"}, r.Trailers.Values("x-content-length"))
+		_, ok := r.Headers.Get("X-Content-Length")
+		assert.False(t, ok)
+	})
+
+	t.Run("chunked body with invalid chunk size", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"zz\r\n" +
+				"hello\r\n" +
+				"0\r\n" +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r.Body)
+		require.ErrorContains(t, err, "error: chunk size is not a valid hex number - chunk size: zz")
+	})
+
+	t.Run("chunked body with chunk data bigger than chunk size", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"3\r\n" +
+				"hello\r\n" +
+				"0\r\n" +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r.Body)
+		require.ErrorContains(t, err, "error: chunk data is not followed by crlf")
+	})
+
+	t.Run("chunked body without the last chunk", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\n" +
+				"hello\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r.Body)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Body content length bigger than the data sent", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /submit HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Content-Length: 20\r\n" +
+				"\r\n" +
+				"partial content",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(r.Body)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, "partial content", string(body))
+	})
+
+	t.Run("Body is not read past the content length", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /submit HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Content-Length: 5\r\n" +
+				"\r\n" +
+				"hello world",
+			numBytesPerRead: 1080,
+		}
+
+		r, err := ParseFromReader(reader)
+		require.NoError(t, err)
+
+		assert.Equal(t, "hello", readBody(t, r))
+		require.NoError(t, r.Body.Close())
+		_, err = r.Body.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, ErrBodyReadAfterClose)
+	})
+
+	t.Run("Close discards the body not read", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\n" +
+				"hello\r\n" +
+				"0\r\n" +
+				"X-Checksum: 42\r\n" +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+		require.NoError(t, err)
+
+		require.NoError(t, r.Body.Close())
+		assert.Equal(t, []string{"42"}, r.Trailers.Values("x-checksum"))
+	})
+
+	t.Run("reader ends before any byte of the request", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestParseFromReaderLimits(t *testing.T) {
+	t.Run("request line longer than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET /a-very-long-request-target HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxRequestLineBytes(16))
+
+		require.Nil(t, r)
+		require.ErrorIs(t, err, ErrRequestLineTooLong)
+	})
+
+	t.Run("request line without crlf longer than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET /aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			numBytesPerRead: 1080,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxRequestLineBytes(16))
+
+		require.Nil(t, r)
+		require.ErrorIs(t, err, ErrRequestLineTooLong)
+	})
+
+	t.Run("header section bigger than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "GET / HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"User-Agent: curl/7.81.0\r\n" +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxHeaderBytes(30))
+
+		require.Nil(t, r)
+		require.ErrorIs(t, err, ErrHeadersTooLarge)
+	})
+
+	t.Run("more header field lines than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "GET / HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"User-Agent: curl/7.81.0\r\n" +
+				"Accept: */*\r\n" +
+				"\r\n",
+			numBytesPerRead: 1080,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxHeaderCount(2))
+
+		require.Nil(t, r)
+		require.ErrorIs(t, err, ErrHeadersTooLarge)
+	})
+
+	t.Run("header field lines equal to the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "GET / HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Accept: */*\r\n" +
+				"\r\n",
+			numBytesPerRead: 1080,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxHeaderCount(2))
+
+		require.NoError(t, err)
+		require.NotNil(t, r)
+	})
+
+	t.Run("more trailer field lines than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"0\r\n" +
+				strings.Repeat("X-T: 1\r\n", 50) +
+				"\r\n",
+			numBytesPerRead: 1080,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxHeaderCount(10))
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r.Body)
+		require.ErrorIs(t, err, ErrHeadersTooLarge)
+	})
+
+	t.Run("trailer section bigger than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"0\r\n" +
+				strings.Repeat("X-T: 1\r\n", 50) +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxHeaderBytes(100))
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r.Body)
+		require.ErrorIs(t, err, ErrHeadersTooLarge)
+	})
+
+	t.Run("content length bigger than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /submit HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Content-Length: 13\r\n" +
+				"\r\n" +
+				"hello world!\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxBodyBytes(12))
+
+		require.Nil(t, r)
+		require.ErrorIs(t, err, ErrBodyTooLarge)
+	})
+
+	t.Run("chunked body bigger than the limit", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST /upload HTTP/1.1\r\n" +
+				"Host: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\n" +
+				"hello\r\n" +
+				"7\r\n" +
+				" world!\r\n" +
+				"0\r\n" +
+				"\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader, WithMaxBodyBytes(8))
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(r.Body)
+		require.ErrorIs(t, err, ErrBodyTooLarge)
+	})
+}
+
+func TestParseFromReaderParseError(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       string
+		opts       []Option
+		kind       ErrorKind
+		statusCode int
+	}{
+		{
+			name:       "malformed request line",
+			data:       "GET /coffee\r\nHost: localhost:42069\r\n\r\n",
+			kind:       KindMalformedRequestLine,
+			statusCode: 400,
+		},
+		{
+			name:       "method not implemented",
+			data:       "PIZZA / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			kind:       KindMethodNotImplemented,
+			statusCode: 501,
+		},
+		{
+			name:       "http version not supported",
+			data:       "GET / HTTP/2.0\r\nHost: localhost:42069\r\n\r\n",
+			kind:       KindVersionNotSupported,
+			statusCode: 505,
+		},
+		{
+			name:       "malformed headers",
+			data:       "GET / HTTP/1.1\r\nH©st: localhost:42069\r\n\r\n",
+			kind:       KindMalformedHeaders,
+			statusCode: 400,
+		},
+		{
+			name:       "transfer encoding without chunked",
+			data:       "POST / HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: gzip\r\n\r\n",
+			kind:       KindInvalidFraming,
+			statusCode: 400,
+		},
+		{
+			name:       "transfer coding not implemented",
+			data:       "POST / HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: gzip, chunked\r\n\r\n",
+			kind:       KindTransferCodingNotImplemented,
+			statusCode: 501,
+		},
+		{
+			name:       "request line too long",
+			data:       "GET /coffee HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			opts:       []Option{WithMaxRequestLineBytes(10)},
+			kind:       KindRequestLineTooLong,
+			statusCode: 414,
+		},
+		{
+			name:       "headers too large",
+			data:       "GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			opts:       []Option{WithMaxHeaderCount(0)},
+			kind:       KindHeadersTooLarge,
+			statusCode: 431,
+		},
+		{
+			name:       "body too large",
+			data:       "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 10\r\n\r\n",
+			opts:       []Option{WithMaxBodyBytes(5)},
+			kind:       KindBodyTooLarge,
+			statusCode: 413,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := &chunkReader{
+				data:            tc.data,
+				numBytesPerRead: 3,
+			}
+
+			r, err := ParseFromReader(reader, tc.opts...)
+
+			require.Nil(t, r)
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tc.kind, parseErr.Kind)
+			assert.Equal(t, tc.statusCode, parseErr.StatusCode)
+		})
+	}
+
+	t.Run("reader ends in the middle of the request is not a parse error", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET / HTTP/1.1\r\nHost: local",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
 		require.Nil(t, r)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		var parseErr *ParseError
+		assert.False(t, errors.As(err, &parseErr))
 	})
 }
 
+func TestParseFromReaderSmuggling(t *testing.T) {
+	testCases := []struct {
+		name   string
+		data   string
+		kind   ErrorKind
+		errMsg string
+	}{
+		{
+			name: "conflicting content length fields",
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"Content-Length: 5\r\nContent-Length: 6\r\n\r\nhello!",
+			kind:   KindInvalidFraming,
+			errMsg: "error: conflicting content length values - content length: 5, 6",
+		},
+		{
+			name:   "conflicting content length list",
+			data:   "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5, 50\r\n\r\nhello",
+			kind:   KindInvalidFraming,
+			errMsg: "error: conflicting content length values - content length: 5, 50",
+		},
+		{
+			name:   "content length with sign",
+			data:   "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: +5\r\n\r\nhello",
+			kind:   KindInvalidFraming,
+			errMsg: "error: content length value is not an int",
+		},
+		{
+			name:   "negative content length",
+			data:   "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: -5\r\n\r\nhello",
+			kind:   KindInvalidFraming,
+			errMsg: "error: content length value is not an int",
+		},
+		{
+			name:   "content length with whitespace in the middle",
+			data:   "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5 0\r\n\r\nhello",
+			kind:   KindInvalidFraming,
+			errMsg: "error: content length value is not an int",
+		},
+		{
+			name:   "content length bigger than int64",
+			data:   "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 99999999999999999999\r\n\r\nhello",
+			kind:   KindInvalidFraming,
+			errMsg: "error: content length value is too big",
+		},
+		{
+			name: "transfer encoding and content length",
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"Content-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+			kind:   KindInvalidFraming,
+			errMsg: "error: transfer encoding and content length are both present",
+		},
+		{
+			name:   "chunked is not the final transfer coding",
+			data:   "POST / HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: chunked, gzip\r\n\r\n0\r\n\r\n",
+			kind:   KindInvalidFraming,
+			errMsg: "error: chunked must be the final transfer coding - transfer encoding: chunked, gzip",
+		},
+		{
+			name: "chunked twice",
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"Transfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+			kind:   KindInvalidFraming,
+			errMsg: "error: chunked must be the final transfer coding - transfer encoding: chunked, chunked",
+		},
+		{
+			name: "obs-fold field line",
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"Transfer-Encoding: gzip\r\n chunked\r\n\r\n0\r\n\r\n",
+			kind:   KindMalformedHeaders,
+			errMsg: "malformed headers - obs-fold field line is not allowed",
+		},
+		{
+			name: "bare LF in the header section",
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"X-Gremio: vamo\nContent-Length: 5\r\n\r\nhello",
+			kind:   KindMalformedHeaders,
+			errMsg: "malformed headers - bare CR or LF in field line",
+		},
+		{
+			name: "control char in a header value",
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"X-Gremio: vamo\x00\r\nContent-Length: 5\r\n\r\nhello",
+			kind:   KindMalformedHeaders,
+			errMsg: "malformed headers - value with not allowed char '\\x00' - header key: X-Gremio",
+		},
+		{
+			name:   "bare LF in the request line",
+			data:   "POST / HTTP/1.1\nHost: localhost:42069\r\n\r\n",
+			kind:   KindMalformedRequestLine,
+			errMsg: "request line with bare CR or LF",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := &chunkReader{
+				data:            tc.data,
+				numBytesPerRead: 3,
+			}
+
+			r, err := ParseFromReader(reader)
+
+			require.Nil(t, r)
+			require.ErrorContains(t, err, tc.errMsg)
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tc.kind, parseErr.Kind)
+		})
+	}
+
+	t.Run("same content length sent many times", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"Content-Length: 5\r\nContent-Length: 5, 5\r\n\r\nhello",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), r.ContentLength)
+		assert.Equal(t, "hello", readBody(t, r))
+	})
+
+	t.Run("chunked transfer coding is case insensitive", func(t *testing.T) {
+		reader := &chunkReader{
+			data: "POST / HTTP/1.1\r\nHost: localhost:42069\r\n" +
+				"Transfer-Encoding: Chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello", readBody(t, r))
+	})
+}
+
+func TestRequestContext(t *testing.T) {
+	t.Run("context is background by default", func(t *testing.T) {
+		r, err := ParseFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+
+		require.NoError(t, err)
+		assert.Equal(t, context.Background(), r.Context())
+	})
+
+	t.Run("with context returns a copy with the context", func(t *testing.T) {
+		r, err := ParseFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+		type key struct{}
+
+		r2 := r.WithContext(context.WithValue(r.Context(), key{}, "gremio"))
+
+		assert.Equal(t, "gremio", r2.Context().Value(key{}))
+		assert.Nil(t, r.Context().Value(key{}))
+		assert.Equal(t, r.URL, r2.URL)
+	})
+}
+
+func readBody(t *testing.T, r *Request) string {
+	t.Helper()
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
 type chunkReader struct {
 	data            string
 	numBytesPerRead int
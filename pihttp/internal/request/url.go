@@ -0,0 +1,260 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TargetForm is one of the four forms of the request-target,
+// see https://datatracker.ietf.org/doc/html/rfc9112#name-request-target
+type TargetForm int
+
+const (
+	// TargetFormOrigin is the absolute path and query used on most requests, /where?q=now.
+	TargetFormOrigin TargetForm = iota
+	// TargetFormAbsolute is a full URI, used on requests to proxies, http://www.example.org/pub/WWW/TheProject.html.
+	TargetFormAbsolute
+	// TargetFormAuthority is host and port, only used by CONNECT, www.example.com:80.
+	TargetFormAuthority
+	// TargetFormAsterisk is a single *, only used by a server wide OPTIONS.
+	TargetFormAsterisk
+)
+
+// URL is the parsed request-target.
+type URL struct {
+	Form TargetForm
+	// Scheme and Host are only set for the absolute form, Host is also set for the authority form.
+	Scheme string
+	Host   string
+	// Path is the decoded path, RawPath is the path as sent by the client.
+	Path     string
+	RawPath  string
+	RawQuery string
+	Fragment string
+	Query    Query
+}
+
+// Query is the decoded query string, a key can be repeated so each key has a list of values.
+type Query map[string][]string
+
+// Get returns the first value of key, or an empty string if the key is not present.
+func (q Query) Get(key string) string {
+	vals := q[key]
+	if len(vals) == 0 {
+		return ""
+	}
+
+	return vals[0]
+}
+
+// Values returns all values of key in the order they were sent.
+func (q Query) Values(key string) []string {
+	return q[key]
+}
+
+// Has reports if key is present, even without a value.
+func (q Query) Has(key string) bool {
+	_, ok := q[key]
+	return ok
+}
+
+// parseRequestTarget parses target into one of the four target forms according to the method.
+func parseRequestTarget(method, target string) (*URL, error) {
+	for i := 0; i < len(target); i++ {
+		if target[i] < 0x21 || target[i] == 0x7f {
+			return nil, fmt.Errorf("request target with not allowed char - request target: %q", target)
+		}
+	}
+
+	if method == MethodConnect {
+		return parseAuthorityForm(target)
+	}
+
+	if target == "*" {
+		if method != MethodOptions {
+			return nil, errors.New("request target * is only allowed to OPTIONS method")
+		}
+		return &URL{Form: TargetFormAsterisk, Path: "*", RawPath: "*", Query: Query{}}, nil
+	}
+
+	if strings.HasPrefix(target, "/") {
+		u := &URL{Form: TargetFormOrigin}
+		if err := u.setPathQueryFragment(target); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+
+	return parseAbsoluteForm(target)
+}
+
+func parseAuthorityForm(target string) (*URL, error) {
+	// an IPv6 host is in brackets, [::1]:443, its colons are not the port separator
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || host == "" || port == "" || strings.ContainsAny(target, "/?#@") {
+		return nil, fmt.Errorf("request target must be in authority form <host>:<port> - request target: %s", target)
+	}
+
+	for _, r := range port {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("request target port is not a number - request target: %s", target)
+		}
+	}
+
+	return &URL{Form: TargetFormAuthority, Host: target, Query: Query{}}, nil
+}
+
+func parseAbsoluteForm(target string) (*URL, error) {
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok || !isScheme(scheme) {
+		return nil, fmt.Errorf("request target is not in origin or absolute form - request target: %s", target)
+	}
+
+	host := rest
+	pathIdx := strings.IndexAny(rest, "/?#")
+	if pathIdx != -1 {
+		host = rest[:pathIdx]
+		rest = rest[pathIdx:]
+	} else {
+		rest = ""
+	}
+
+	if host == "" {
+		return nil, fmt.Errorf("request target in absolute form without host - request target: %s", target)
+	}
+
+	u := &URL{
+		Form:   TargetFormAbsolute,
+		Scheme: strings.ToLower(scheme),
+		Host:   host,
+	}
+
+	if err := u.setPathQueryFragment(rest); err != nil {
+		return nil, err
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = "/"
+	}
+
+	return u, nil
+}
+
+// isScheme reports if s is ALPHA *( ALPHA / DIGIT / "+" / "-" / "." ), see https://datatracker.ietf.org/doc/html/rfc3986#section-3.1
+func isScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		isAlpha := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if isAlpha {
+			continue
+		}
+		if i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+
+	return true
+}
+
+func (u *URL) setPathQueryFragment(s string) error {
+	s, fragment, _ := strings.Cut(s, "#")
+	rawPath, rawQuery, _ := strings.Cut(s, "?")
+
+	path, err := unescape(rawPath, false)
+	if err != nil {
+		return err
+	}
+
+	decodedFragment, err := unescape(fragment, false)
+	if err != nil {
+		return err
+	}
+
+	query, err := parseQuery(rawQuery)
+	if err != nil {
+		return err
+	}
+
+	u.Path = path
+	u.RawPath = rawPath
+	u.RawQuery = rawQuery
+	u.Fragment = decodedFragment
+	u.Query = query
+
+	return nil
+}
+
+// parseQuery decodes a query in the application/x-www-form-urlencoded format, key=val&key2=val2.
+func parseQuery(rawQuery string) (Query, error) {
+	query := Query{}
+
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+
+		rawKey, rawVal, _ := strings.Cut(pair, "=")
+
+		key, err := unescape(rawKey, true)
+		if err != nil {
+			return nil, err
+		}
+
+		val, err := unescape(rawVal, true)
+		if err != nil {
+			return nil, err
+		}
+
+		query[key] = append(query[key], val)
+	}
+
+	return query, nil
+}
+
+// unescape decodes the percent-encoded octets of s, see https://datatracker.ietf.org/doc/html/rfc3986#section-2.1
+// When plusAsSpace is true + is decoded as a space, as the query string uses it.
+func unescape(s string, plusAsSpace bool) (string, error) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, nil
+	}
+
+	decoded := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", fmt.Errorf("invalid percent-encoding - got: %s", s)
+			}
+			decoded.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		case s[i] == '+' && plusAsSpace:
+			decoded.WriteByte(' ')
+		default:
+			decoded.WriteByte(s[i])
+		}
+	}
+
+	return decoded.String(), nil
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
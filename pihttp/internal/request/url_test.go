@@ -0,0 +1,192 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestTarget(t *testing.T) {
+	t.Run("origin form with path query and fragment", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodGet, "/caf%C3%A9/menu?item=coffee&item=tea&size=big+cup&empty#top%20of")
+
+		require.NoError(t, err)
+		assert.Equal(t, TargetFormOrigin, u.Form)
+		assert.Equal(t, "/café/menu", u.Path)
+		assert.Equal(t, "/caf%C3%A9/menu", u.RawPath)
+		assert.Equal(t, "item=coffee&item=tea&size=big+cup&empty", u.RawQuery)
+		assert.Equal(t, "top of", u.Fragment)
+		assert.Equal(t, "coffee", u.Query.Get("item"))
+		assert.Equal(t, []string{"coffee", "tea"}, u.Query.Values("item"))
+		assert.Equal(t, "big cup", u.Query.Get("size"))
+		assert.True(t, u.Query.Has("empty"))
+		assert.False(t, u.Query.Has("gremio"))
+		assert.Equal(t, "", u.Query.Get("gremio"))
+	})
+
+	t.Run("origin form only with path", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodGet, "/")
+
+		require.NoError(t, err)
+		assert.Equal(t, "/", u.Path)
+		assert.Empty(t, u.RawQuery)
+		assert.Empty(t, u.Query)
+	})
+
+	t.Run("absolute form", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodGet, "HTTP://www.example.org:8080/pub/WWW/TheProject.html?a=1")
+
+		require.NoError(t, err)
+		assert.Equal(t, TargetFormAbsolute, u.Form)
+		assert.Equal(t, "http", u.Scheme)
+		assert.Equal(t, "www.example.org:8080", u.Host)
+		assert.Equal(t, "/pub/WWW/TheProject.html", u.Path)
+		assert.Equal(t, "1", u.Query.Get("a"))
+	})
+
+	t.Run("absolute form without path", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodGet, "http://www.example.org?a=1")
+
+		require.NoError(t, err)
+		assert.Equal(t, "www.example.org", u.Host)
+		assert.Equal(t, "/", u.Path)
+		assert.Equal(t, "1", u.Query.Get("a"))
+	})
+
+	t.Run("authority form", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodConnect, "www.example.com:443")
+
+		require.NoError(t, err)
+		assert.Equal(t, TargetFormAuthority, u.Form)
+		assert.Equal(t, "www.example.com:443", u.Host)
+	})
+
+	t.Run("authority form with IPv6 host", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodConnect, "[::1]:443")
+
+		require.NoError(t, err)
+		assert.Equal(t, TargetFormAuthority, u.Form)
+		assert.Equal(t, "[::1]:443", u.Host)
+	})
+
+	t.Run("asterisk form", func(t *testing.T) {
+		u, err := parseRequestTarget(MethodOptions, "*")
+
+		require.NoError(t, err)
+		assert.Equal(t, TargetFormAsterisk, u.Form)
+	})
+
+	invalidTestCases := []struct {
+		name   string
+		method string
+		target string
+		errMsg string
+	}{
+		{
+			name:   "asterisk form with method other than OPTIONS",
+			method: MethodGet,
+			target: "*",
+			errMsg: "request target * is only allowed to OPTIONS method",
+		},
+		{
+			name:   "CONNECT without port",
+			method: MethodConnect,
+			target: "www.example.com",
+			errMsg: "request target must be in authority form <host>:<port>",
+		},
+		{
+			name:   "CONNECT with IPv6 host without brackets",
+			method: MethodConnect,
+			target: "::1:443",
+			errMsg: "request target must be in authority form <host>:<port>",
+		},
+		{
+			name:   "CONNECT with empty IPv6 host",
+			method: MethodConnect,
+			target: "[]:443",
+			errMsg: "request target must be in authority form <host>:<port>",
+		},
+		{
+			name:   "CONNECT with path",
+			method: MethodConnect,
+			target: "/www.example.com:443",
+			errMsg: "request target must be in authority form <host>:<port>",
+		},
+		{
+			name:   "CONNECT with port not a number",
+			method: MethodConnect,
+			target: "www.example.com:https",
+			errMsg: "request target port is not a number",
+		},
+		{
+			name:   "not origin nor absolute form",
+			method: MethodGet,
+			target: "coffee",
+			errMsg: "request target is not in origin or absolute form",
+		},
+		{
+			name:   "absolute form without host",
+			method: MethodGet,
+			target: "http:///coffee",
+			errMsg: "request target in absolute form without host",
+		},
+		{
+			name:   "invalid percent-encoding in path",
+			method: MethodGet,
+			target: "/coffee%zz",
+			errMsg: "invalid percent-encoding - got: /coffee%zz",
+		},
+		{
+			name:   "truncated percent-encoding in query",
+			method: MethodGet,
+			target: "/coffee?flavor=dark%2",
+			errMsg: "invalid percent-encoding - got: dark%2",
+		},
+		{
+			name:   "control char",
+			method: MethodGet,
+			target: "/coffee\x7f",
+			errMsg: "request target with not allowed char",
+		},
+	}
+
+	for _, tc := range invalidTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := parseRequestTarget(tc.method, tc.target)
+
+			require.Nil(t, u)
+			require.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+}
+
+func TestParseFromReaderURL(t *testing.T) {
+	t.Run("request has the parsed request target", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET /coffee?flavor=dark+mode HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.NoError(t, err)
+		assert.Equal(t, "/coffee?flavor=dark+mode", r.RequestLine.RequestTarget)
+		assert.Equal(t, "/coffee", r.URL.Path)
+		assert.Equal(t, "dark mode", r.URL.Query.Get("flavor"))
+	})
+
+	t.Run("invalid request target is a malformed request line", func(t *testing.T) {
+		reader := &chunkReader{
+			data:            "GET /coffee%g HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			numBytesPerRead: 3,
+		}
+
+		r, err := ParseFromReader(reader)
+
+		require.Nil(t, r)
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		assert.Equal(t, KindMalformedRequestLine, parseErr.Kind)
+	})
+}
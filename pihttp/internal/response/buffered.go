@@ -0,0 +1,203 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/headers"
+)
+
+// DefaultBufferSize is the size of the body buffered by Write before the response is sent.
+const DefaultBufferSize = 4 << 10
+
+// Header returns the headers sent by WriteHeader or Write. Changing them after the response is sent
+// has no effect, except for the values of the trailers registered in the Trailer header,
+// they are sent by Finish after a chunked body.
+func (w *Writer) Header() headers.Headers {
+	return w.header
+}
+
+// WriteHeader sets the status code of the response written with Write. The status line is not sent yet,
+// it is sent with the headers once the body is buffered or by Finish.
+// Only the first call has effect and it has no effect after WriteStatusLine.
+func (w *Writer) WriteHeader(statusCode int) {
+	if w.buffered || w.state != writerStateStatusLine {
+		return
+	}
+
+	w.buffered = true
+	w.bufferedStatus = statusCode
+}
+
+// Write writes p to the body of the response, sending the 200 status code if WriteHeader was not called.
+//
+// The body is buffered up to the buffer size, if the whole body fits in it the response is sent by
+// Finish with the Content-Length of the body. When the buffer is full the response is sent chunked,
+// unless Header has a Content-Length set by the handler.
+func (w *Writer) Write(p []byte) (int, error) {
+	if !w.buffered && w.state != writerStateStatusLine {
+		return 0, fmt.Errorf("cannot write body in state %d, use WriteBody", w.state)
+	}
+	if w.state == writerStateDone {
+		return 0, errors.New("cannot write body, the response is done")
+	}
+	w.WriteHeader(StatusOK)
+	if !bodyAllowed(w.bufferedStatus) && len(p) > 0 {
+		return 0, ErrBodyNotAllowed
+	}
+
+	if w.streaming {
+		return w.writeStream(p)
+	}
+
+	if len(w.buf)+len(p) <= w.bufferSize {
+		w.buf = append(w.buf, p...)
+		return len(p), nil
+	}
+
+	if err := w.startStream(); err != nil {
+		return 0, err
+	}
+
+	body := append(w.buf, p...)
+	w.buf = nil
+	if _, err := w.writeStream(body); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Finish sends the response written with Header, WriteHeader and Write. A buffered body is sent with
+// its Content-Length and a chunked body is ended with the trailers of Header. When nothing was written
+// Finish sends 200 with an empty body. The server calls it after the handler returns.
+func (w *Writer) Finish() error {
+	if !w.buffered && w.state == writerStateStatusLine {
+		w.WriteHeader(StatusOK)
+	}
+	if !w.buffered || w.state == writerStateDone {
+		return nil
+	}
+
+	if !w.streaming {
+		// the trailers can only be sent after a chunked body
+		if len(trailerNames(w.header)) > 0 {
+			if err := w.startStream(); err != nil {
+				return err
+			}
+			if len(w.buf) > 0 {
+				if _, err := w.writeStream(w.buf); err != nil {
+					return err
+				}
+			}
+		} else {
+			return w.sendBuffered()
+		}
+	}
+
+	if !w.chunked && !w.closeDelimited {
+		w.state = writerStateDone
+		return nil
+	}
+
+	if _, err := w.WriteChunkedBodyDone(); err != nil {
+		return err
+	}
+
+	return w.WriteTrailers(w.header)
+}
+
+// sendBuffered sends the status line, the headers and the body buffered with its Content-Length.
+func (w *Writer) sendBuffered() error {
+	h := w.responseHeaders()
+	if _, ok := h.Get("Content-Length"); !ok && bodyAllowed(w.bufferedStatus) {
+		h.Set("Content-Length", strconv.Itoa(len(w.buf)))
+	}
+	// the status line is not sent when the headers can not be, so the server can still answer
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("error: invalid headers err: %w", err)
+	}
+
+	if err := w.writeStatusLine(w.bufferedStatus); err != nil {
+		return err
+	}
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+
+	_, err := w.WriteBody(w.buf)
+	w.buf = nil
+	w.state = writerStateDone
+
+	return err
+}
+
+// startStream sends the status line and the headers of a body that does not fit in the buffer.
+// The body is sent chunked unless the handler set the Content-Length.
+func (w *Writer) startStream() error {
+	h := w.responseHeaders()
+	if _, ok := h.Get("Content-Length"); !ok && bodyAllowed(w.bufferedStatus) {
+		h.Set("Transfer-Encoding", "chunked")
+	}
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("error: invalid headers err: %w", err)
+	}
+
+	if err := w.writeStatusLine(w.bufferedStatus); err != nil {
+		return err
+	}
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+	w.streaming = true
+
+	return nil
+}
+
+// writeStream writes p as the next part of a body which headers were sent by startStream.
+func (w *Writer) writeStream(p []byte) (int, error) {
+	if w.noBody {
+		return w.discardBody(p)
+	}
+
+	if w.chunked || w.closeDelimited {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if _, err := w.WriteChunkedBody(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if w.state != writerStateBody {
+		return 0, errors.New("cannot write body, the response is done")
+	}
+
+	n, err := w.writer.Write(p)
+	w.bodyBytes += n
+
+	return n, err
+}
+
+// responseHeaders returns a copy of Header to be sent as the headers, without the trailers
+// registered in the Trailer header.
+func (w *Writer) responseHeaders() headers.Headers {
+	h := headers.New()
+	for key, vals := range w.header {
+		for _, val := range vals {
+			h.Add(key, val)
+		}
+	}
+
+	if _, ok := h.Get("Content-Type"); !ok && bodyAllowed(w.bufferedStatus) {
+		h.Set("Content-Type", "text/plain")
+	}
+
+	for _, tName := range trailerNames(w.header) {
+		h.Del(tName)
+	}
+
+	return h
+}
@@ -0,0 +1,248 @@
+package response
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterBuffered(t *testing.T) {
+	fixedDate(t, time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC))
+
+	t.Run("small body is sent with content length and status 200", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		_, err := w.Write([]byte("hello "))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("world"))
+		require.NoError(t, err)
+		assert.Empty(t, out.String(), "body is buffered until Finish")
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Length: 11\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n"+
+			"hello world", out.String())
+		assert.Equal(t, StatusOK, w.Status())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("status and bytes are reported before the response is sent", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		assert.Equal(t, 0, w.Status())
+		w.WriteHeader(StatusCreated)
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+
+		assert.Equal(t, StatusCreated, w.Status())
+		assert.Equal(t, 5, w.BytesWritten())
+		assert.False(t, w.Committed())
+		assert.Empty(t, out.String())
+
+		require.NoError(t, w.Finish())
+		assert.Equal(t, StatusCreated, w.Status())
+		assert.Equal(t, 5, w.BytesWritten())
+		assert.True(t, w.Committed())
+	})
+
+	t.Run("bytes of a chunked body are reported with the ones still buffered", func(t *testing.T) {
+		w := NewWriter(new(bytes.Buffer), WithBufferSize(4))
+
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("!"))
+		require.NoError(t, err)
+
+		assert.Equal(t, 6, w.BytesWritten())
+		assert.True(t, w.Committed())
+	})
+
+	t.Run("status and headers are sent as set", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/users/42")
+		w.WriteHeader(StatusCreated)
+		w.WriteHeader(StatusOK)
+		_, err := w.Write([]byte(`{"id":42}`))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 201 Created\r\n"+
+			"Content-Length: 9\r\n"+
+			"Content-Type: application/json\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Location: /users/42\r\n"+
+			"\r\n"+
+			`{"id":42}`, out.String())
+	})
+
+	t.Run("write header without body", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		w.WriteHeader(StatusAccepted)
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 202 Accepted\r\n"+
+			"Content-Length: 0\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("body bigger than the buffer is sent chunked", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithBufferSize(8))
+
+		_, err := w.Write([]byte("hello "))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("world"))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("!"))
+		require.NoError(t, err)
+		assert.False(t, w.KeepAlive(), "chunked body is not done")
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Transfer-Encoding: chunked\r\n"+
+			"\r\n"+
+			"b\r\nhello world\r\n"+
+			"1\r\n!\r\n"+
+			"0\r\n"+
+			"\r\n", out.String())
+		assert.Equal(t, 12, w.BytesWritten())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("body bigger than the buffer with content length is streamed as is", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithBufferSize(8))
+
+		w.Header().Set("Content-Length", "12")
+		_, err := w.Write([]byte("hello world!"))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Length: 12\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n"+
+			"hello world!", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("trailers are sent after a chunked body", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		w.Header().Add("Trailer", "X-Content-Length")
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+		w.Header().Set("X-Content-Length", "5")
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Trailer: X-Content-Length\r\n"+
+			"Transfer-Encoding: chunked\r\n"+
+			"\r\n"+
+			"5\r\nhello\r\n"+
+			"0\r\n"+
+			"X-Content-Length: 5\r\n"+
+			"\r\n", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("body bigger than the buffer to a HTTP/1.0 client is delimited by the connection close", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithBufferSize(4), WithRequestVersion(1, 0))
+
+		_, err := w.Write([]byte("hello world"))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+
+		assert.True(t, strings.HasSuffix(out.String(), "Connection: close\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n"+
+			"hello world"), out.String())
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("invalid header is not sent", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		w.Header().Set("Location", "/\r\nSet-Cookie: admin=true")
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+
+		assert.Error(t, w.Finish())
+		assert.Empty(t, out.String())
+		assert.False(t, w.Committed())
+	})
+
+	t.Run("write after finish", func(t *testing.T) {
+		w := NewWriter(new(bytes.Buffer))
+
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+
+		_, err = w.Write([]byte("hello"))
+		assert.Error(t, err)
+		assert.NoError(t, w.Finish())
+	})
+
+	t.Run("finish sends 200 with an empty body when nothing was written", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Length: 0\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.Equal(t, StatusOK, w.Status())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("finish does nothing when write status line was called", func(t *testing.T) {
+		w := NewWriter(new(bytes.Buffer))
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(DefaultHeaders(0)))
+		require.NoError(t, w.Finish())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("write and write status line can not be mixed", func(t *testing.T) {
+		w := NewWriter(new(bytes.Buffer))
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+		assert.Error(t, w.WriteStatusLine(StatusOK))
+
+		w = NewWriter(new(bytes.Buffer))
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		_, err = w.Write([]byte("hello"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,37 @@
+package response
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// TimeFormat is the IMF-fixdate format of the Date header, always in GMT,
+// see https://datatracker.ietf.org/doc/html/rfc9110#name-date-time-formats
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// date is the Date header value shared by all Writers.
+var date = &dateCache{now: time.Now}
+
+// dateCache formats the Date header once per second, all responses written on the same second share it.
+type dateCache struct {
+	now    func() time.Time
+	cached atomic.Pointer[cachedDate]
+}
+
+type cachedDate struct {
+	unix int64
+	val  string
+}
+
+func (c *dateCache) get(now time.Time) string {
+	unix := now.Unix()
+
+	if cached := c.cached.Load(); cached != nil && cached.unix == unix {
+		return cached.val
+	}
+
+	val := now.UTC().Format(TimeFormat)
+	c.cached.Store(&cachedDate{unix: unix, val: val})
+
+	return val
+}
@@ -0,0 +1,21 @@
+package response
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateCache(t *testing.T) {
+	c := &dateCache{}
+	at := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.FixedZone("", -3*60*60))
+
+	assert.Equal(t, "Sun, 06 Nov 1994 11:49:37 GMT", c.get(at))
+	cached := c.cached.Load()
+
+	assert.Equal(t, "Sun, 06 Nov 1994 11:49:37 GMT", c.get(at.Add(999*time.Millisecond)))
+	assert.Same(t, cached, c.cached.Load(), "date is formatted once per second")
+
+	assert.Equal(t, "Sun, 06 Nov 1994 11:49:38 GMT", c.get(at.Add(time.Second)))
+}
@@ -0,0 +1,109 @@
+package response
+
+type options struct {
+	keepAlive      bool
+	protoMajor     int
+	protoMinor     int
+	expectContinue bool
+	serverName     string
+	bufferSize     int
+	head           bool
+}
+
+type Option interface {
+	apply(*options)
+}
+
+// WithKeepAlive tells the Writer if the connection can be reused after the response.
+// When keepAlive is false the Writer will send Connection: close on WriteHeaders.
+func WithKeepAlive(keepAlive bool) Option {
+	return &optionWithKeepAlive{
+		keepAlive: keepAlive,
+	}
+}
+
+type optionWithKeepAlive struct {
+	keepAlive bool
+}
+
+func (o *optionWithKeepAlive) apply(opts *options) {
+	opts.keepAlive = o.keepAlive
+}
+
+// WithRequestVersion tells the Writer the HTTP version of the request being answered.
+// HTTP/1.0 clients do not know chunked encoding and only keep the connection alive when
+// the response has Connection: keep-alive. The default is HTTP/1.1.
+func WithRequestVersion(major, minor int) Option {
+	return &optionWithRequestVersion{
+		major: major,
+		minor: minor,
+	}
+}
+
+type optionWithRequestVersion struct {
+	major int
+	minor int
+}
+
+func (o *optionWithRequestVersion) apply(opts *options) {
+	opts.protoMajor = o.major
+	opts.protoMinor = o.minor
+}
+
+// WithExpectContinue tells the Writer the client sent Expect: 100-continue and waits for
+// WriteContinue before sending the body. If the final response is written before WriteContinue
+// the client may never send the body, so the Writer sends Connection: close.
+func WithExpectContinue() Option {
+	return &optionWithExpectContinue{}
+}
+
+type optionWithExpectContinue struct{}
+
+func (o *optionWithExpectContinue) apply(opts *options) {
+	opts.expectContinue = true
+}
+
+// WithHeadRequest tells the Writer it answers a HEAD request. The status line and the headers are sent
+// as they would be for a GET request, Content-Length and Transfer-Encoding included, but the body is not.
+func WithHeadRequest() Option {
+	return &optionWithHeadRequest{}
+}
+
+type optionWithHeadRequest struct{}
+
+func (o *optionWithHeadRequest) apply(opts *options) {
+	opts.head = true
+}
+
+// WithServerHeader sets the Server header sent on every response, unless the handler sets it.
+// By default no Server header is sent.
+func WithServerHeader(name string) Option {
+	return &optionWithServerHeader{
+		name: name,
+	}
+}
+
+type optionWithServerHeader struct {
+	name string
+}
+
+func (o *optionWithServerHeader) apply(opts *options) {
+	opts.serverName = o.name
+}
+
+// WithBufferSize sets the size of the body buffered by Write before the response is sent.
+// A body that fits in the buffer is sent with Content-Length, a bigger one is sent chunked.
+// The default is DefaultBufferSize, a size of zero or less sends every body chunked.
+func WithBufferSize(size int) Option {
+	return &optionWithBufferSize{
+		size: size,
+	}
+}
+
+type optionWithBufferSize struct {
+	size int
+}
+
+func (o *optionWithBufferSize) apply(opts *options) {
+	opts.bufferSize = o.size
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/gpbPiazza/httpfromtcp/internal/headers"
@@ -13,6 +14,9 @@ const (
 	crfl = "\r\n"
 )
 
+// ErrBodyNotAllowed is returned when writing a body to a 1xx, 204 or 304 response, they never have one.
+var ErrBodyNotAllowed = errors.New("error: response status does not allow a body")
+
 type writerState int
 
 const (
@@ -20,38 +24,97 @@ const (
 	writerStateHeaders
 	writerStateBody
 	writerStateTrailers
+	writerStateDone
 )
 
 type Writer struct {
 	writer io.Writer
 	state  writerState
+	status int
+
+	keepAlive bool
+	// http10 is true when answering a HTTP/1.0 request.
+	http10  bool
+	headers headers.Headers
+	chunked bool
+	// closeDelimited is true when the body is delimited by closing the connection,
+	// that is how a chunked body is sent to a HTTP/1.0 client.
+	closeDelimited bool
+	bodyBytes      int
+	// head is true when answering a HEAD request.
+	head bool
+	// noBody is true when the body is not sent, the response to a HEAD request or with a status without body.
+	noBody bool
+
+	expectContinue bool
+	continueSent   bool
+
+	serverName string
+
+	// header, bufferedStatus and buf are the response written by Header, WriteHeader and Write,
+	// see buffered.go. buffered is true once WriteHeader or Write is called.
+	header         headers.Headers
+	buffered       bool
+	bufferedStatus int
+	buf            []byte
+	bufferSize     int
+	streaming      bool
 }
 
-func NewWriter(w io.Writer) *Writer {
+func NewWriter(w io.Writer, opts ...Option) *Writer {
+	option := options{
+		keepAlive:  true,
+		protoMajor: 1,
+		protoMinor: 1,
+		bufferSize: DefaultBufferSize,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&option)
+	}
+
 	return &Writer{
-		writer: w,
-		state:  writerStateStatusLine,
+		writer:    w,
+		state:     writerStateStatusLine,
+		keepAlive: option.keepAlive,
+		http10:    option.protoMajor == 1 && option.protoMinor == 0,
+		headers:   headers.New(),
+
+		head:           option.head,
+		expectContinue: option.expectContinue,
+		serverName:     option.serverName,
+
+		header:     headers.New(),
+		bufferSize: option.bufferSize,
 	}
 }
 
 func DefaultHeaders(bodyLen int) headers.Headers {
 	h := headers.New()
 
-	h.Override("Connection", "close")
-	h.Override("Content-Type", "text/plain")
-	h.Override("Content-Length", fmt.Sprintf("%d", bodyLen))
+	h.Set("Content-Type", "text/plain")
+	h.Set("Content-Length", fmt.Sprintf("%d", bodyLen))
 
 	return h
 }
 
 func (w *Writer) WriteStatusLine(statusCode int) error {
+	if w.buffered {
+		return errors.New("cannot write status line of a response written with Write or WriteHeader")
+	}
+
+	return w.writeStatusLine(statusCode)
+}
+
+func (w *Writer) writeStatusLine(statusCode int) error {
 	if w.state != writerStateStatusLine {
 		return fmt.Errorf("cannot write status line in state %d", w.state)
 	}
 	defer func() { w.state = writerStateHeaders }()
+	w.status = statusCode
 
 	httpVersion := "HTTP/1.1"
-	reasonPhrase := reasonPhrase(statusCode)
+	reasonPhrase := ReasonPhrase(statusCode)
 
 	statusLine := fmt.Sprintf("%s %d %s%s", httpVersion, statusCode, reasonPhrase, crfl)
 
@@ -63,18 +126,112 @@ func (w *Writer) WriteStatusLine(statusCode int) error {
 	return nil
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
+// Status returns the status code of the status line written or set by WriteHeader or Write,
+// or 0 if there is none yet.
+func (w *Writer) Status() int {
+	if w.status == 0 && w.buffered {
+		return w.bufferedStatus
+	}
+
+	return w.status
+}
+
+// Committed reports if the status line was written, from then on the response can not be replaced
+// by another one. A response buffered by Write is not committed until the buffer is sent.
+func (w *Writer) Committed() bool {
+	return w.status != 0
+}
+
+// BytesWritten returns the number of body bytes written so far, without the chunked encoding framing.
+// The bytes buffered by Write are counted, the body of a HEAD response is not.
+func (w *Writer) BytesWritten() int {
+	if w.head {
+		return w.bodyBytes
+	}
+
+	return w.bodyBytes + len(w.buf)
+}
+
+// WriteContinue writes the interim 100 Continue response to a client that sent Expect: 100-continue,
+// telling it to send the request body. WriteContinue does nothing if the client did not ask for it,
+// if it was already sent or if the final response status line was already written.
+func (w *Writer) WriteContinue() error {
+	if !w.expectContinue || w.continueSent || w.state != writerStateStatusLine {
+		return nil
+	}
+	w.continueSent = true
+
+	statusLine := fmt.Sprintf("HTTP/1.1 %d %s%s%s", StatusContinue, ReasonPhrase(StatusContinue), crfl, crfl)
+	if _, err := w.writer.Write([]byte(statusLine)); err != nil {
+		return fmt.Errorf("error: writing continue status line err: %s", err)
+	}
+
+	return nil
+}
+
+// WriteHeaders writes the field lines of h and the default ones. Nothing is written if a key
+// or value of h is not valid, a value with CR or LF would split the response, the returned
+// error wraps the *headers.InvalidValueError.
+func (w *Writer) WriteHeaders(h headers.Headers) error {
 	if w.state != writerStateHeaders {
 		return fmt.Errorf("cannot write headers in state %d", w.state)
 	}
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("error: invalid headers err: %w", err)
+	}
 	defer func() { w.state = writerStateBody }()
 
-	fieldLines := new(strings.Builder)
-	for key, val := range headers {
-		fiedlLine := fmt.Sprintf("%s: %s%s", key, val, crfl)
+	// the client is still waiting to send the body, it can not be read as the next request
+	if w.expectContinue && !w.continueSent {
+		w.keepAlive = false
+	}
 
-		if _, err := fieldLines.WriteString(fiedlLine); err != nil {
-			return fmt.Errorf("error to write field line err: %s", err)
+	for key, vals := range h {
+		w.headers.Del(key)
+		for _, val := range vals {
+			w.headers.Add(key, val)
+		}
+	}
+	if _, ok := w.headers.Get("Date"); !ok {
+		w.headers.Set("Date", date.get(date.now()))
+	}
+	if _, ok := w.headers.Get("Server"); !ok && w.serverName != "" {
+		w.headers.Set("Server", w.serverName)
+	}
+	if !bodyAllowed(w.status) && w.status != StatusNotModified {
+		// a 304 can have the framing headers the 200 would have, 1xx and 204 never have them
+		w.headers.Del("Content-Length")
+		w.headers.Del("Transfer-Encoding")
+	}
+	w.noBody = w.head || !bodyAllowed(w.status)
+	w.chunked = w.headers.HasToken("Transfer-Encoding", "chunked")
+	if w.chunked && w.http10 {
+		w.headers.Del("Transfer-Encoding")
+		w.headers.Del("Trailer")
+		w.closeDelimited = true
+		w.keepAlive = false
+	}
+
+	// the handler can close the connection the client asked to keep alive
+	if w.headers.HasToken("Connection", "close") {
+		w.keepAlive = false
+	}
+
+	if !w.keepAlive {
+		w.headers.Set("Connection", "close")
+	} else if w.http10 && !w.headers.HasToken("Connection", "keep-alive") {
+		w.headers.Set("Connection", "keep-alive")
+	}
+
+	fieldLines := new(strings.Builder)
+	for _, key := range w.headers.Keys() {
+		// each value is its own field line, Set-Cookie values can not be joined by a comma
+		for _, val := range w.headers.Values(key) {
+			fiedlLine := fmt.Sprintf("%s: %s%s", headers.CanonicalKey(key), val, crfl)
+
+			if _, err := fieldLines.WriteString(fiedlLine); err != nil {
+				return fmt.Errorf("error to write field line err: %s", err)
+			}
 		}
 	}
 
@@ -97,7 +254,14 @@ func (w *Writer) WriteBody(body []byte) (int, error) {
 
 	defer func() { w.state = writerStateTrailers }()
 
-	return w.writer.Write(body)
+	if w.noBody {
+		return w.discardBody(body)
+	}
+
+	n, err := w.writer.Write(body)
+	w.bodyBytes += n
+
+	return n, err
 }
 
 // WriteChunkedBody will write into conn the body in following format below:
@@ -123,10 +287,23 @@ func (w *Writer) WriteBody(body []byte) (int, error) {
 // WriteChunkedBody will write a new line in the chunked body to each call.
 //
 // To finish writing into chunked body you must call WriteChunkedBodyDone.
+//
+// HTTP/1.0 clients do not know chunked encoding, for them the chunk is written as is
+// and the end of the body is the connection close.
 func (w *Writer) WriteChunkedBody(chunk []byte) (int, error) {
 	if w.state != writerStateBody {
 		return 0, fmt.Errorf("cannot write body in state %d", w.state)
 	}
+
+	if w.noBody {
+		return w.discardBody(chunk)
+	}
+
+	if w.closeDelimited {
+		n, err := w.writer.Write(chunk)
+		w.bodyBytes += n
+		return n, err
+	}
 	chunkSize := len(chunk)
 
 	numberTotal := 0
@@ -137,6 +314,7 @@ func (w *Writer) WriteChunkedBody(chunk []byte) (int, error) {
 	numberTotal += numberBytes
 
 	numberBytes, err = w.writer.Write(chunk)
+	w.bodyBytes += numberBytes
 	if err != nil {
 		return numberTotal, err
 	}
@@ -157,30 +335,40 @@ func (w *Writer) WriteChunkedBodyDone() (int, error) {
 
 	defer func() { w.state = writerStateTrailers }()
 
+	if w.closeDelimited || w.noBody {
+		return 0, nil
+	}
+
 	return w.writer.Write([]byte(fmt.Sprintf("%d%s", 0, crfl)))
 }
 
+// WriteTrailers will write the trailer fields registered in the Trailer header and the
+// final crlf of the chunked body. If header has no Trailer key only the final crlf is written.
+// Nothing is written if a key or value of header is not valid.
 func (w *Writer) WriteTrailers(header headers.Headers) error {
 	if w.state != writerStateTrailers {
 		return fmt.Errorf("cannot write trailer body in state %d", w.state)
 	}
-
-	trailers, ok := header.Get("Trailer")
-	if !ok {
-		return errors.New("no trailer header key")
+	if err := header.Validate(); err != nil {
+		return fmt.Errorf("error: invalid trailers err: %w", err)
 	}
+	defer func() { w.state = writerStateDone }()
 
-	trailerNames := strings.Split(trailers, headers.ValSeparator)
+	if w.closeDelimited || w.noBody {
+		return nil
+	}
 
-	for _, tName := range trailerNames {
-		tVal, ok := header.Get(tName)
-		if !ok {
+	for _, tName := range trailerNames(header) {
+		tVals := header.Values(tName)
+		if len(tVals) == 0 {
 			return errors.New("registered trailer name not present into header")
 		}
 
-		_, err := w.writer.Write([]byte(fmt.Sprintf("%s: %s%s", tName, tVal, crfl)))
-		if err != nil {
-			return err
+		for _, tVal := range tVals {
+			_, err := w.writer.Write([]byte(fmt.Sprintf("%s: %s%s", headers.CanonicalKey(tName), tVal, crfl)))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -191,3 +379,74 @@ func (w *Writer) WriteTrailers(header headers.Headers) error {
 
 	return nil
 }
+
+// discardBody is the body write of a response that has no body. The body of a HEAD response is
+// silently discarded, the handler runs the same code for HEAD and GET.
+func (w *Writer) discardBody(body []byte) (int, error) {
+	if w.head || len(body) == 0 {
+		return len(body), nil
+	}
+
+	return 0, ErrBodyNotAllowed
+}
+
+// bodyAllowed reports if a response with statusCode can have a body,
+// see https://datatracker.ietf.org/doc/html/rfc9112#name-message-body-length
+func bodyAllowed(statusCode int) bool {
+	informational := statusCode >= 100 && statusCode < 200
+
+	return !informational && statusCode != StatusNoContent && statusCode != StatusNotModified
+}
+
+// trailerNames returns the names registered in the Trailer header. The names can be sent in many
+// Trailer field lines and each one can be a comma separated list.
+func trailerNames(h headers.Headers) []string {
+	var names []string
+	for _, trailers := range h.Values("Trailer") {
+		for _, tName := range strings.Split(trailers, ",") {
+			if tName = strings.TrimSpace(tName); tName != "" {
+				names = append(names, tName)
+			}
+		}
+	}
+
+	return names
+}
+
+// KeepAlive reports if the connection can be reused after the response written so far.
+// The response must be complete and framed by Content-Length or chunked encoding,
+// otherwise the client has no way to know where the next response starts.
+func (w *Writer) KeepAlive() bool {
+	if !w.keepAlive {
+		return false
+	}
+
+	if w.state == writerStateStatusLine || w.state == writerStateHeaders {
+		return false
+	}
+
+	if w.headers.HasToken("Connection", "close") {
+		return false
+	}
+
+	// the response ends with the headers
+	if w.noBody {
+		return true
+	}
+
+	if w.chunked {
+		return w.state == writerStateDone
+	}
+
+	contentLength, ok := w.headers.Get("Content-Length")
+	if !ok {
+		return false
+	}
+
+	n, err := strconv.Atoi(contentLength)
+	if err != nil {
+		return false
+	}
+
+	return n == w.bodyBytes
+}
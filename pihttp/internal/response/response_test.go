@@ -0,0 +1,332 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterWriteHeaders(t *testing.T) {
+	fixedDate(t, time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC))
+
+	t.Run("headers are written sorted and in canonical form", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithServerHeader("pinet"))
+		h := DefaultHeaders(5)
+		h.Add("x-request-id", "42")
+		h.Add("CACHE-CONTROL", "no-cache")
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(h))
+		_, err := w.WriteBody([]byte("hello"))
+		require.NoError(t, err)
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Cache-Control: no-cache\r\n"+
+			"Content-Length: 5\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Server: pinet\r\n"+
+			"X-Request-Id: 42\r\n"+
+			"\r\n"+
+			"hello", out.String())
+	})
+
+	t.Run("the same headers are always written the same way", func(t *testing.T) {
+		var written []string
+		for range 20 {
+			out := new(bytes.Buffer)
+			w := NewWriter(out, WithKeepAlive(false))
+			h := DefaultHeaders(0)
+			h.Add("a", "1")
+			h.Add("b", "2")
+			h.Add("c", "3")
+
+			require.NoError(t, w.WriteStatusLine(StatusOK))
+			require.NoError(t, w.WriteHeaders(h))
+			written = append(written, out.String())
+		}
+
+		for _, got := range written {
+			assert.Equal(t, written[0], got)
+		}
+	})
+
+	t.Run("each value is written in its own field line", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+		h := DefaultHeaders(0)
+		h.Add("Set-Cookie", "a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT")
+		h.Add("Set-Cookie", "b=2")
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(h))
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Length: 0\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Set-Cookie: a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT\r\n"+
+			"Set-Cookie: b=2\r\n"+
+			"\r\n", out.String())
+	})
+
+	t.Run("HTTP/1.0 keep-alive connection is kept alive", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithRequestVersion(1, 0))
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(DefaultHeaders(0)))
+
+		assert.Contains(t, out.String(), "Connection: keep-alive\r\n")
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("HTTP/1.0 keep-alive connection is closed when the handler sets Connection: close", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithRequestVersion(1, 0))
+		h := DefaultHeaders(0)
+		h.Set("Connection", "close")
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(h))
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Connection: close\r\n"+
+			"Content-Length: 0\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.False(t, w.KeepAlive())
+	})
+
+	t.Run("a value with crlf is not written", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+		h := DefaultHeaders(0)
+		h.Set("Location", "/home\r\nSet-Cookie: admin=true")
+
+		require.NoError(t, w.WriteStatusLine(StatusFound))
+		err := w.WriteHeaders(h)
+
+		var valErr *headers.InvalidValueError
+		require.True(t, errors.As(err, &valErr))
+		assert.Equal(t, "location", valErr.Key)
+		assert.Equal(t, "HTTP/1.1 302 Found\r\n", out.String())
+
+		h.Set("Location", "/home")
+		require.NoError(t, w.WriteHeaders(h))
+		assert.Contains(t, out.String(), "Location: /home\r\n")
+		assert.NotContains(t, out.String(), "Set-Cookie")
+	})
+
+	t.Run("trailers are written in canonical form", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+		h := DefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", "x-content-sha256")
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(h))
+		_, err := w.WriteChunkedBodyDone()
+		require.NoError(t, err)
+		h.Add("x-content-sha256", "abc")
+		require.NoError(t, w.WriteTrailers(h))
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Trailer: x-content-sha256\r\n"+
+			"Transfer-Encoding: chunked\r\n"+
+			"\r\n"+
+			"0\r\n"+
+			"X-Content-Sha256: abc\r\n"+
+			"\r\n", out.String())
+	})
+
+	t.Run("trailers registered in many Trailer field lines are all written", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+		h := DefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		h.Add("Trailer", "X-Content-Sha256,X-Content-Length")
+		h.Add("Trailer", "Server-Timing")
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(h))
+		_, err := w.WriteChunkedBodyDone()
+		require.NoError(t, err)
+		out.Reset()
+		h.Add("X-Content-Sha256", "abc")
+		h.Add("X-Content-Length", "0")
+		h.Add("Server-Timing", "db;dur=53")
+		h.Add("Server-Timing", "app;dur=47.2")
+		require.NoError(t, w.WriteTrailers(h))
+
+		assert.Equal(t, "X-Content-Sha256: abc\r\n"+
+			"X-Content-Length: 0\r\n"+
+			"Server-Timing: db;dur=53\r\n"+
+			"Server-Timing: app;dur=47.2\r\n"+
+			"\r\n", out.String())
+	})
+}
+
+// fixedDate makes the Writers send at as the Date header until the test ends.
+func fixedDate(t *testing.T, at time.Time) {
+	t.Helper()
+
+	original := date
+	date = &dateCache{now: func() time.Time { return at }}
+	t.Cleanup(func() { date = original })
+}
+
+func TestWriterNoBody(t *testing.T) {
+	fixedDate(t, time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC))
+
+	t.Run("HEAD response has the headers of the GET response and no body", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithHeadRequest())
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(DefaultHeaders(5)))
+		n, err := w.WriteBody([]byte("hello"))
+		require.NoError(t, err)
+		assert.Equal(t, 5, n)
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Length: 5\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.Equal(t, 0, w.BytesWritten())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("HEAD response to a chunked body has no chunks", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithHeadRequest())
+		h := DefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", "X-Content-Length")
+
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		require.NoError(t, w.WriteHeaders(h))
+		_, err := w.WriteChunkedBody([]byte("hello"))
+		require.NoError(t, err)
+		_, err = w.WriteChunkedBodyDone()
+		require.NoError(t, err)
+		h.Set("X-Content-Length", "5")
+		require.NoError(t, w.WriteTrailers(h))
+
+		assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"Trailer: X-Content-Length\r\n"+
+			"Transfer-Encoding: chunked\r\n"+
+			"\r\n", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("HEAD response written with write has the computed framing headers", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out, WithHeadRequest(), WithBufferSize(8))
+
+		_, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+		assert.Contains(t, out.String(), "Content-Length: 5\r\n")
+		assert.True(t, strings.HasSuffix(out.String(), "\r\n\r\n"), out.String())
+		assert.NotContains(t, out.String(), "hello")
+
+		out = new(bytes.Buffer)
+		w = NewWriter(out, WithHeadRequest(), WithBufferSize(8))
+		_, err = w.Write([]byte("hello world"))
+		require.NoError(t, err)
+		require.NoError(t, w.Finish())
+		assert.Contains(t, out.String(), "Transfer-Encoding: chunked\r\n")
+		assert.True(t, strings.HasSuffix(out.String(), "\r\n\r\n"), out.String())
+		assert.NotContains(t, out.String(), "hello")
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("204 response has no body and no framing headers", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		require.NoError(t, w.WriteStatusLine(StatusNoContent))
+		require.NoError(t, w.WriteHeaders(DefaultHeaders(0)))
+		_, err := w.WriteBody([]byte("hello"))
+		assert.ErrorIs(t, err, ErrBodyNotAllowed)
+
+		assert.Equal(t, "HTTP/1.1 204 No Content\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("304 response keeps the content length of the 200 response and has no body", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		require.NoError(t, w.WriteStatusLine(StatusNotModified))
+		require.NoError(t, w.WriteHeaders(DefaultHeaders(42)))
+		_, err := w.WriteBody([]byte("hello"))
+		assert.ErrorIs(t, err, ErrBodyNotAllowed)
+
+		assert.Equal(t, "HTTP/1.1 304 Not Modified\r\n"+
+			"Content-Length: 42\r\n"+
+			"Content-Type: text/plain\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+
+	t.Run("204 response written with write header", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w := NewWriter(out)
+
+		w.WriteHeader(StatusNoContent)
+		_, err := w.Write([]byte("hello"))
+		assert.ErrorIs(t, err, ErrBodyNotAllowed)
+		require.NoError(t, w.Finish())
+
+		assert.Equal(t, "HTTP/1.1 204 No Content\r\n"+
+			"Date: Sun, 06 Nov 1994 08:49:37 GMT\r\n"+
+			"\r\n", out.String())
+		assert.True(t, w.KeepAlive())
+	})
+}
+
+func TestBodyAllowed(t *testing.T) {
+	testCases := []struct {
+		statusCode int
+		expected   bool
+	}{
+		{statusCode: StatusContinue, expected: false},
+		{statusCode: 101, expected: false},
+		{statusCode: 199, expected: false},
+		{statusCode: StatusOK, expected: true},
+		{statusCode: StatusNoContent, expected: false},
+		{statusCode: StatusFound, expected: true},
+		{statusCode: StatusNotModified, expected: false},
+		{statusCode: StatusNotFound, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d", tc.statusCode), func(t *testing.T) {
+			assert.Equal(t, tc.expected, bodyAllowed(tc.statusCode))
+		})
+	}
+}
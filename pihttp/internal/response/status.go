@@ -70,9 +70,9 @@ const (
 	StatusNetworkAuthenticationRequired = 511
 )
 
-// reasonPhrase returns a text for the HTTP status code. It returns the empty
+// ReasonPhrase returns a text for the HTTP status code. It returns the empty
 // string if the code is unknown.
-func reasonPhrase(code int) string {
+func ReasonPhrase(code int) string {
 	switch code {
 	case StatusContinue:
 		return "Continue"
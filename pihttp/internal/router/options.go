@@ -0,0 +1,27 @@
+package router
+
+import "github.com/gpbPiazza/httpfromtcp/internal/server"
+
+type options struct {
+	notFoundHandler server.Handler
+}
+
+type Option interface {
+	apply(*options)
+}
+
+// WithNotFoundHandler sets the handler of the requests whose path matches no pattern,
+// by default they are answered with a plain text 404 Not Found.
+func WithNotFoundHandler(handler server.Handler) Option {
+	return &optionWithNotFoundHandler{
+		handler: handler,
+	}
+}
+
+type optionWithNotFoundHandler struct {
+	handler server.Handler
+}
+
+func (o *optionWithNotFoundHandler) apply(opts *options) {
+	opts.notFoundHandler = o.handler
+}
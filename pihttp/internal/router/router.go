@@ -0,0 +1,193 @@
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/request"
+	"github.com/gpbPiazza/httpfromtcp/internal/response"
+	"github.com/gpbPiazza/httpfromtcp/internal/server"
+)
+
+// Router dispatches requests to the handler registered for the request method and path.
+//
+// A pattern is a path where a whole segment can be a path parameter, /users/{id}, or the last segment
+// can be a wildcard matching the rest of the path, /static/*. The matched values are set on
+// request.Request PathParams, the wildcard under the * key. Paths are split into segments as sent by the
+// client, an encoded slash %2F does not split a segment, and the matched values are decoded.
+//
+// A request whose path matches no pattern is answered with 404 Not Found, a request whose path matches
+// but not the method is answered with 405 Method Not Allowed and the Allow header.
+// OPTIONS requests are answered with the Allow header unless an OPTIONS handler is registered.
+type Router struct {
+	root            *node
+	notFoundHandler server.Handler
+	middlewares     []server.Middleware
+}
+
+func New(opts ...Option) *Router {
+	option := options{
+		notFoundHandler: notFound,
+	}
+
+	for _, opt := range opts {
+		opt.apply(&option)
+	}
+
+	return &Router{
+		root:            &node{},
+		notFoundHandler: option.notFoundHandler,
+	}
+}
+
+// Handle registers handler for method and pattern.
+// Handle panics if the pattern is invalid or it is already registered for method.
+func (rt *Router) Handle(method, pattern string, handler server.Handler) {
+	if !slices.Contains(request.AllMethods, method) {
+		panic(fmt.Sprintf("router: method not implemented - method: %s", method))
+	}
+
+	rt.root.insert(method, pattern, handler)
+}
+
+func (rt *Router) Get(pattern string, handler server.Handler) {
+	rt.Handle(request.MethodGet, pattern, handler)
+}
+
+func (rt *Router) Post(pattern string, handler server.Handler) {
+	rt.Handle(request.MethodPost, pattern, handler)
+}
+
+func (rt *Router) Put(pattern string, handler server.Handler) {
+	rt.Handle(request.MethodPut, pattern, handler)
+}
+
+func (rt *Router) Patch(pattern string, handler server.Handler) {
+	rt.Handle(request.MethodPatch, pattern, handler)
+}
+
+func (rt *Router) Delete(pattern string, handler server.Handler) {
+	rt.Handle(request.MethodDelete, pattern, handler)
+}
+
+// Use adds middlewares wrapping every request dispatched by the router, including the ones answered with
+// 404, 405 or the automatic OPTIONS. See server.Chain for the order they run.
+// Use must be called before Handler, the middlewares added after are not used.
+func (rt *Router) Use(middlewares ...server.Middleware) {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+}
+
+// Handler returns the server.Handler dispatching the requests to the registered handlers.
+func (rt *Router) Handler() server.Handler {
+	return server.Chain(rt.serve, rt.middlewares...)
+}
+
+func (rt *Router) serve(w *response.Writer, req *request.Request) {
+	method := req.RequestLine.Method
+
+	if req.URL.Form == request.TargetFormAsterisk {
+		// OPTIONS * asks for the capabilities of the whole server
+		var allowed []string
+		rt.root.methods(func(m string) { allowed = append(allowed, m) })
+		writeAllow(w, response.StatusOK, allowed)
+		return
+	}
+
+	params := make(map[string]string)
+	found := rt.root.lookup(matchPath(req.URL.RawPath), params)
+	if found == nil {
+		rt.notFoundHandler(w, req)
+		return
+	}
+	for name, val := range params {
+		params[name] = unescapeSegments(val)
+	}
+
+	handler, ok := found.handlers[method]
+	if !ok && method == request.MethodHead {
+		// HEAD runs the GET handler, the server does not send the body
+		handler, ok = found.handlers[request.MethodGet]
+	}
+	if ok {
+		req.PathParams = params
+		handler(w, req)
+		return
+	}
+
+	allowed := make([]string, 0, len(found.handlers))
+	for m := range found.handlers {
+		allowed = append(allowed, m)
+	}
+
+	if method == request.MethodOptions {
+		writeAllow(w, response.StatusOK, allowed)
+		return
+	}
+
+	writeAllow(w, response.StatusMethodNotAllowed, allowed)
+}
+
+// segmentEscaper escapes the chars of a decoded segment that would change how the path is split.
+var segmentEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// matchPath returns the path routes are matched against: the raw path split into segments, each one
+// decoded but for the % and / chars, so /users/a%2Fb is the two segments users and a%2Fb
+// and not the three segments of /users/a/b, and /caf%C3%A9 still matches the route /café.
+func matchPath(rawPath string) string {
+	segments := strings.Split(rawPath, "/")
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			continue
+		}
+		segments[i] = segmentEscaper.Replace(decoded)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// unescapeSegments decodes a path parameter or wildcard value matched against matchPath.
+func unescapeSegments(val string) string {
+	decoded, err := url.PathUnescape(val)
+	if err != nil {
+		return val
+	}
+
+	return decoded
+}
+
+// writeAllow answers with statusCode and the Allow header listing allowed and OPTIONS, that is always allowed.
+// HEAD is allowed when GET is.
+func writeAllow(w *response.Writer, statusCode int, allowed []string) {
+	methods := []string{request.MethodOptions}
+	for _, m := range allowed {
+		if m == request.MethodGet {
+			methods = append(methods, request.MethodHead)
+		}
+		if m != request.MethodOptions {
+			methods = append(methods, m)
+		}
+	}
+	slices.Sort(methods)
+	methods = slices.Compact(methods)
+
+	var body []byte
+	if statusCode != response.StatusOK {
+		body = []byte(fmt.Sprintf("%d %s\n", statusCode, response.ReasonPhrase(statusCode)))
+	}
+
+	_ = w.WriteStatusLine(statusCode)
+	h := response.DefaultHeaders(len(body))
+	h.Set("Allow", strings.Join(methods, ", "))
+	_ = w.WriteHeaders(h)
+	_, _ = w.WriteBody(body)
+}
+
+func notFound(w *response.Writer, _ *request.Request) {
+	body := []byte(fmt.Sprintf("%d %s\n", response.StatusNotFound, response.ReasonPhrase(response.StatusNotFound)))
+	_ = w.WriteStatusLine(response.StatusNotFound)
+	_ = w.WriteHeaders(response.DefaultHeaders(len(body)))
+	_, _ = w.WriteBody(body)
+}
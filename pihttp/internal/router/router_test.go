@@ -0,0 +1,386 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/request"
+	"github.com/gpbPiazza/httpfromtcp/internal/response"
+	"github.com/gpbPiazza/httpfromtcp/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	r := New()
+	r.Get("/", nameHandler("root"))
+	r.Get("/users", nameHandler("users"))
+	r.Post("/users", nameHandler("create user"))
+	r.Get("/users/me", nameHandler("me"))
+	r.Get("/users/{id}", nameHandler("user"))
+	r.Delete("/users/{id}", nameHandler("delete user"))
+	r.Get("/users/{id}/posts/{postID}", nameHandler("post"))
+	r.Get("/userspace", nameHandler("userspace"))
+	r.Get("/static/*", nameHandler("static"))
+	r.Handle(request.MethodOptions, "/custom", nameHandler("custom options"))
+
+	testCases := []struct {
+		name       string
+		method     string
+		target     string
+		statusCode int
+		body       string
+		allow      string
+	}{
+		{
+			name:       "root",
+			method:     request.MethodGet,
+			target:     "/",
+			statusCode: http.StatusOK,
+			body:       "root",
+		},
+		{
+			name:       "static path",
+			method:     request.MethodGet,
+			target:     "/users",
+			statusCode: http.StatusOK,
+			body:       "users",
+		},
+		{
+			name:       "same path other method",
+			method:     request.MethodPost,
+			target:     "/users",
+			statusCode: http.StatusOK,
+			body:       "create user",
+		},
+		{
+			name:       "static path sharing a prefix",
+			method:     request.MethodGet,
+			target:     "/userspace?q=1",
+			statusCode: http.StatusOK,
+			body:       "userspace",
+		},
+		{
+			name:       "static segment wins over path parameter",
+			method:     request.MethodGet,
+			target:     "/users/me",
+			statusCode: http.StatusOK,
+			body:       "me",
+		},
+		{
+			name:       "path parameter",
+			method:     request.MethodGet,
+			target:     "/users/42",
+			statusCode: http.StatusOK,
+			body:       "user id=42",
+		},
+		{
+			name:       "path parameter sharing a prefix with a static segment",
+			method:     request.MethodGet,
+			target:     "/users/meow",
+			statusCode: http.StatusOK,
+			body:       "user id=meow",
+		},
+		{
+			name:       "many path parameters",
+			method:     request.MethodGet,
+			target:     "/users/42/posts/7",
+			statusCode: http.StatusOK,
+			body:       "post id=42 postID=7",
+		},
+		{
+			name:       "path parameter is decoded",
+			method:     request.MethodGet,
+			target:     "/users/caf%C3%A9",
+			statusCode: http.StatusOK,
+			body:       "user id=café",
+		},
+		{
+			name:       "encoded slash is part of the path parameter",
+			method:     request.MethodGet,
+			target:     "/users/a%2Fb",
+			statusCode: http.StatusOK,
+			body:       "user id=a/b",
+		},
+		{
+			name:       "encoded slash is part of the segment before a path parameter",
+			method:     request.MethodGet,
+			target:     "/users/a%2Fposts/7",
+			statusCode: http.StatusNotFound,
+			body:       "404 Not Found\n",
+		},
+		{
+			name:       "encoded percent in path parameter",
+			method:     request.MethodGet,
+			target:     "/users/100%252F",
+			statusCode: http.StatusOK,
+			body:       "user id=100%2F",
+		},
+		{
+			name:       "encoded slash does not end a static segment",
+			method:     request.MethodGet,
+			target:     "/users%2Fme",
+			statusCode: http.StatusNotFound,
+			body:       "404 Not Found\n",
+		},
+		{
+			name:       "encoded static segment",
+			method:     request.MethodGet,
+			target:     "/users/%6De",
+			statusCode: http.StatusOK,
+			body:       "me",
+		},
+		{
+			name:       "wildcard",
+			method:     request.MethodGet,
+			target:     "/static/css/main.css",
+			statusCode: http.StatusOK,
+			body:       "static *=css/main.css",
+		},
+		{
+			name:       "wildcard with encoded slash",
+			method:     request.MethodGet,
+			target:     "/static/css%2F@evil.com/main.css",
+			statusCode: http.StatusOK,
+			body:       "static *=css/@evil.com/main.css",
+		},
+		{
+			name:       "wildcard matching nothing",
+			method:     request.MethodGet,
+			target:     "/static/",
+			statusCode: http.StatusOK,
+			body:       "static *=",
+		},
+		{
+			name:       "not found",
+			method:     request.MethodGet,
+			target:     "/coffee",
+			statusCode: http.StatusNotFound,
+			body:       "404 Not Found\n",
+		},
+		{
+			name:       "empty path parameter is not found",
+			method:     request.MethodGet,
+			target:     "/users/",
+			statusCode: http.StatusNotFound,
+			body:       "404 Not Found\n",
+		},
+		{
+			name:       "path longer than the pattern is not found",
+			method:     request.MethodGet,
+			target:     "/users/42/posts",
+			statusCode: http.StatusNotFound,
+			body:       "404 Not Found\n",
+		},
+		{
+			name:       "method not allowed",
+			method:     request.MethodPut,
+			target:     "/users/42",
+			statusCode: http.StatusMethodNotAllowed,
+			body:       "405 Method Not Allowed\n",
+			allow:      "DELETE, GET, HEAD, OPTIONS",
+		},
+		{
+			name:       "HEAD runs the GET handler",
+			method:     request.MethodHead,
+			target:     "/users/42",
+			statusCode: http.StatusOK,
+			body:       "user id=42",
+		},
+		{
+			name:       "HEAD without GET",
+			method:     request.MethodHead,
+			target:     "/custom",
+			statusCode: http.StatusMethodNotAllowed,
+			body:       "405 Method Not Allowed\n",
+			allow:      "OPTIONS",
+		},
+		{
+			name:       "automatic OPTIONS",
+			method:     request.MethodOptions,
+			target:     "/users",
+			statusCode: http.StatusOK,
+			allow:      "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name:       "registered OPTIONS",
+			method:     request.MethodOptions,
+			target:     "/custom",
+			statusCode: http.StatusOK,
+			body:       "custom options",
+		},
+		{
+			name:       "OPTIONS of the whole server",
+			method:     request.MethodOptions,
+			target:     "*",
+			statusCode: http.StatusOK,
+			allow:      "DELETE, GET, HEAD, OPTIONS, POST",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := serve(t, r, tc.method, tc.target)
+
+			assert.Equal(t, tc.statusCode, resp.StatusCode)
+			assert.Equal(t, tc.body, readResponseBody(t, resp))
+			assert.Equal(t, tc.allow, resp.Header.Get("Allow"))
+		})
+	}
+}
+
+func TestRouterNotFoundHandler(t *testing.T) {
+	r := New(WithNotFoundHandler(func(w *response.Writer, req *request.Request) {
+		body := []byte("nothing at " + req.URL.Path)
+		_ = w.WriteStatusLine(response.StatusNotFound)
+		_ = w.WriteHeaders(response.DefaultHeaders(len(body)))
+		_, _ = w.WriteBody(body)
+	}))
+	r.Get("/", nameHandler("root"))
+
+	resp := serve(t, r, request.MethodGet, "/coffee")
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "nothing at /coffee", readResponseBody(t, resp))
+}
+
+func TestRouterUse(t *testing.T) {
+	var calls []string
+	record := func(name string) server.Middleware {
+		return func(next server.Handler) server.Handler {
+			return func(w *response.Writer, req *request.Request) {
+				calls = append(calls, name)
+				next(w, req)
+			}
+		}
+	}
+	r := New()
+	r.Use(record("first"), record("second"))
+	r.Get("/", nameHandler("root"))
+
+	resp := serve(t, r, request.MethodGet, "/")
+	assert.Equal(t, "root", readResponseBody(t, resp))
+	assert.Equal(t, []string{"first", "second"}, calls)
+
+	calls = nil
+	resp = serve(t, r, request.MethodGet, "/coffee")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestRouterHandlePanics(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		pattern  string
+		panicMsg string
+	}{
+		{
+			name:     "pattern without leading slash",
+			method:   request.MethodGet,
+			pattern:  "users",
+			panicMsg: "router: pattern must start with / - pattern: users",
+		},
+		{
+			name:     "route already registered",
+			method:   request.MethodGet,
+			pattern:  "/users/{id}",
+			panicMsg: "router: route already registered - method: GET pattern: /users/{id}",
+		},
+		{
+			name:     "path parameter with other name on the same segment",
+			method:   request.MethodPost,
+			pattern:  "/users/{userID}",
+			panicMsg: "router: path parameter {userID} conflicts with {id} on the same segment - pattern: /users/{userID}",
+		},
+		{
+			name:     "path parameter not a whole segment",
+			method:   request.MethodGet,
+			pattern:  "/users-{id}",
+			panicMsg: "router: parameter must be a whole path segment - pattern: /users-{id}",
+		},
+		{
+			name:     "path parameter followed by more than a slash",
+			method:   request.MethodGet,
+			pattern:  "/files/{name}.txt",
+			panicMsg: "router: invalid path parameter - pattern: /files/{name}.txt",
+		},
+		{
+			name:     "path parameter not closed",
+			method:   request.MethodGet,
+			pattern:  "/files/{name",
+			panicMsg: "router: invalid path parameter - pattern: /files/{name",
+		},
+		{
+			name:     "wildcard not the last segment",
+			method:   request.MethodGet,
+			pattern:  "/static/*/css",
+			panicMsg: "router: wildcard must be the last path segment - pattern: /static/*/css",
+		},
+		{
+			name:     "method not implemented",
+			method:   "PIZZA",
+			pattern:  "/pizza",
+			panicMsg: "router: method not implemented - method: PIZZA",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New()
+			r.Get("/users/{id}", nameHandler("user"))
+
+			assert.PanicsWithValue(t, tc.panicMsg, func() {
+				r.Handle(tc.method, tc.pattern, nameHandler("panic"))
+			})
+		})
+	}
+}
+
+// nameHandler answers with name and the sorted path parameters.
+func nameHandler(name string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		body := name
+		for _, key := range []string{"id", "postID", "*"} {
+			if val, ok := req.PathParams[key]; ok {
+				body += fmt.Sprintf(" %s=%s", key, val)
+			}
+		}
+
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.DefaultHeaders(len(body)))
+		_, _ = w.WriteBody([]byte(body))
+	}
+}
+
+// serve sends a request to r and returns the response it wrote.
+func serve(t *testing.T, r *Router, method, target string) *http.Response {
+	t.Helper()
+
+	req, err := request.ParseFromReader(strings.NewReader(
+		fmt.Sprintf("%s %s HTTP/1.1\r\nHost: localhost:42069\r\n\r\n", method, target),
+	))
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	r.Handler()(response.NewWriter(out), req)
+
+	resp, err := http.ReadResponse(bufio.NewReader(out), nil)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func readResponseBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
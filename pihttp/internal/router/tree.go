@@ -0,0 +1,216 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/server"
+)
+
+// wildcardParam is the PathParams key of the path matched by a wildcard, /static/* sets it to what follows /static/.
+const wildcardParam = "*"
+
+// node is a node of the radix tree of routes. Static parts of the patterns share their common prefixes,
+// path parameters and wildcards are children of their own, so /users/{id} and /users/me live side by side.
+//
+// On lookup static children are tried first, then the path parameter and then the wildcard,
+// so /users/me wins over /users/{id} and /users/{id} wins over /users/*.
+type node struct {
+	// prefix is the static part of the path matched by the node, empty on parameter and wildcard nodes.
+	prefix   string
+	children []*node
+
+	param     *node
+	paramName string
+	wildcard  *node
+
+	// pattern and handlers are only set when a route ends on the node, handlers are keyed by method.
+	pattern  string
+	handlers map[string]server.Handler
+}
+
+// insert adds the route pattern of method to the tree, it panics if the pattern is invalid
+// or conflicts with the routes already added.
+func (n *node) insert(method, pattern string, handler server.Handler) {
+	if !strings.HasPrefix(pattern, "/") {
+		panic(fmt.Sprintf("router: pattern must start with / - pattern: %s", pattern))
+	}
+
+	current := n
+	rest := pattern
+	for rest != "" {
+		start := strings.IndexAny(rest, "{*")
+		if start == -1 {
+			current = current.insertStatic(rest)
+			break
+		}
+
+		current = current.insertStatic(rest[:start])
+		if !strings.HasSuffix(rest[:start], "/") {
+			panic(fmt.Sprintf("router: parameter must be a whole path segment - pattern: %s", pattern))
+		}
+
+		if rest[start] == '*' {
+			if start != len(rest)-1 {
+				panic(fmt.Sprintf("router: wildcard must be the last path segment - pattern: %s", pattern))
+			}
+			if current.wildcard == nil {
+				current.wildcard = &node{}
+			}
+			current = current.wildcard
+			break
+		}
+
+		end := strings.IndexByte(rest, '}')
+		name := ""
+		if end != -1 {
+			name = rest[start+1 : end]
+		}
+		if name == "" || strings.ContainsAny(name, "{/*") || (end+1 < len(rest) && rest[end+1] != '/') {
+			panic(fmt.Sprintf("router: invalid path parameter - pattern: %s", pattern))
+		}
+
+		if current.param == nil {
+			current.param = &node{paramName: name}
+		} else if current.param.paramName != name {
+			panic(fmt.Sprintf(
+				"router: path parameter {%s} conflicts with {%s} on the same segment - pattern: %s",
+				name,
+				current.param.paramName,
+				pattern,
+			))
+		}
+		current = current.param
+		rest = rest[end+1:]
+	}
+
+	if current.handlers == nil {
+		current.handlers = make(map[string]server.Handler)
+	}
+	if _, ok := current.handlers[method]; ok {
+		panic(fmt.Sprintf("router: route already registered - method: %s pattern: %s", method, pattern))
+	}
+	current.pattern = pattern
+	current.handlers[method] = handler
+}
+
+// insertStatic walks down the static children matching path, splitting the node whose prefix
+// only partially matches, and returns the node where path ends.
+func (n *node) insertStatic(path string) *node {
+	current := n
+	for path != "" {
+		child := current.staticChild(path[0])
+		if child == nil {
+			child = &node{prefix: path}
+			current.children = append(current.children, child)
+			return child
+		}
+
+		common := commonPrefixLen(child.prefix, path)
+		if common < len(child.prefix) {
+			child.split(common)
+		}
+
+		current = child
+		path = path[common:]
+	}
+
+	return current
+}
+
+// split moves everything after the first i bytes of the node prefix into a new child.
+func (n *node) split(i int) {
+	child := &node{
+		prefix:   n.prefix[i:],
+		children: n.children,
+		param:    n.param,
+		wildcard: n.wildcard,
+		pattern:  n.pattern,
+		handlers: n.handlers,
+	}
+
+	n.prefix = n.prefix[:i]
+	n.children = []*node{child}
+	n.param = nil
+	n.wildcard = nil
+	n.pattern = ""
+	n.handlers = nil
+}
+
+func (n *node) staticChild(c byte) *node {
+	for _, child := range n.children {
+		if child.prefix[0] == c {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the node of the route matching path and sets the matched parameters into params,
+// or nil if no route matches.
+func (n *node) lookup(path string, params map[string]string) *node {
+	if path == "" {
+		if n.handlers != nil {
+			return n
+		}
+		if n.wildcard != nil {
+			params[wildcardParam] = ""
+			return n.wildcard
+		}
+		return nil
+	}
+
+	if child := n.staticChild(path[0]); child != nil && strings.HasPrefix(path, child.prefix) {
+		if found := child.lookup(path[len(child.prefix):], params); found != nil {
+			return found
+		}
+	}
+
+	if n.param != nil {
+		end := strings.IndexByte(path, '/')
+		if end == -1 {
+			end = len(path)
+		}
+
+		if end > 0 {
+			if found := n.param.lookup(path[end:], params); found != nil {
+				params[n.param.paramName] = path[:end]
+				return found
+			}
+		}
+	}
+
+	if n.wildcard != nil {
+		params[wildcardParam] = path
+		return n.wildcard
+	}
+
+	return nil
+}
+
+// methods calls fn with every method registered in the tree.
+func (n *node) methods(fn func(method string)) {
+	for method := range n.handlers {
+		fn(method)
+	}
+
+	for _, child := range n.children {
+		child.methods(fn)
+	}
+	if n.param != nil {
+		n.param.methods(fn)
+	}
+	if n.wildcard != nil {
+		n.wildcard.methods(fn)
+	}
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/request"
+	"github.com/gpbPiazza/httpfromtcp/internal/response"
+)
+
+// AccessLogFormat is the format of the lines written by NewAccessLogHandler.
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON writes one JSON object per request, see slog.JSONHandler.
+	AccessLogJSON AccessLogFormat = iota
+	// AccessLogLogfmt writes one key=value line per request, see slog.TextHandler.
+	AccessLogLogfmt
+	// AccessLogCombined writes the Apache combined log format:
+	// 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.1" 200 2326 "http://example.com/" "curl/8.0"
+	AccessLogCombined
+)
+
+// The attribute keys of the access log records.
+const (
+	AccessLogKeyMethod     = "method"
+	AccessLogKeyTarget     = "target"
+	AccessLogKeyProto      = "proto"
+	AccessLogKeyStatus     = "status"
+	AccessLogKeyBytes      = "bytes"
+	AccessLogKeyDuration   = "duration"
+	AccessLogKeyRemoteAddr = "remote_addr"
+	AccessLogKeyUserAgent  = "user_agent"
+	AccessLogKeyReferer    = "referer"
+	AccessLogKeyConnID     = "conn_id"
+	AccessLogKeyRequestID  = "request_id"
+)
+
+const accessLogMsg = "access"
+
+// NewAccessLogHandler returns a slog.Handler writing the access log records to w in format,
+// use it with WithAccessLog.
+func NewAccessLogHandler(w io.Writer, format AccessLogFormat) slog.Handler {
+	switch format {
+	case AccessLogLogfmt:
+		return slog.NewTextHandler(w, nil)
+	case AccessLogCombined:
+		return &combinedHandler{w: w, mu: new(sync.Mutex)}
+	default:
+		return slog.NewJSONHandler(w, nil)
+	}
+}
+
+// accessLogEntry is what is known of a request when it is logged.
+type accessLogEntry struct {
+	req        *request.Request
+	resp       *response.Writer
+	start      time.Time
+	duration   time.Duration
+	remoteAddr string
+	connID     string
+	requestID  string
+}
+
+// logAccess writes the access log record of a served request, if the access log is enabled.
+// The req of entry is nil when the request could not be parsed, only the status sent is known.
+func (s *Server) logAccess(entry accessLogEntry) {
+	if s.accessLogger == nil {
+		return
+	}
+
+	ctx := context.Background()
+	handler := s.accessLogger.Handler()
+	if !handler.Enabled(ctx, slog.LevelInfo) {
+		return
+	}
+
+	var method, target, proto, userAgent, referer string
+	if entry.req != nil {
+		method = entry.req.RequestLine.Method
+		target = entry.req.RequestLine.RequestTarget
+		proto = "HTTP/" + entry.req.RequestLine.HttpVersion
+		userAgent, _ = entry.req.Headers.Get("User-Agent")
+		referer, _ = entry.req.Headers.Get("Referer")
+	}
+
+	record := slog.NewRecord(entry.start, slog.LevelInfo, accessLogMsg, 0)
+	record.AddAttrs(
+		slog.String(AccessLogKeyMethod, method),
+		slog.String(AccessLogKeyTarget, target),
+		slog.String(AccessLogKeyProto, proto),
+		slog.Int(AccessLogKeyStatus, entry.resp.Status()),
+		slog.Int(AccessLogKeyBytes, entry.resp.BytesWritten()),
+		slog.Duration(AccessLogKeyDuration, entry.duration),
+		slog.String(AccessLogKeyRemoteAddr, entry.remoteAddr),
+		slog.String(AccessLogKeyUserAgent, userAgent),
+		slog.String(AccessLogKeyReferer, referer),
+		slog.String(AccessLogKeyConnID, entry.connID),
+		slog.String(AccessLogKeyRequestID, entry.requestID),
+	)
+
+	_ = handler.Handle(ctx, record)
+}
+
+// combinedHandler is a slog.Handler writing the records in the Apache combined log format.
+// Only the access log attributes are used, groups are ignored.
+type combinedHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	attrs []slog.Attr
+}
+
+func (h *combinedHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h *combinedHandler) Handle(_ context.Context, record slog.Record) error {
+	vals := make(map[string]string)
+	for _, attr := range h.attrs {
+		vals[attr.Key] = attr.Value.String()
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		vals[attr.Key] = attr.Value.String()
+		return true
+	})
+
+	host := dashIfEmpty(vals[AccessLogKeyRemoteAddr])
+	if addr, _, err := net.SplitHostPort(host); err == nil {
+		host = addr
+	}
+
+	bytes := vals[AccessLogKeyBytes]
+	if bytes == "" || bytes == "0" {
+		bytes = "-"
+	}
+
+	// a request that could not be parsed has no request line to log
+	requestLine := "-"
+	if vals[AccessLogKeyMethod] != "" {
+		requestLine = fmt.Sprintf("%s %s %s", vals[AccessLogKeyMethod], vals[AccessLogKeyTarget], vals[AccessLogKeyProto])
+	}
+
+	line := fmt.Sprintf(
+		"%s - - [%s] \"%s\" %s %s %s %s\n",
+		host,
+		record.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		requestLine,
+		dashIfEmpty(vals[AccessLogKeyStatus]),
+		bytes,
+		strconv.Quote(dashIfEmpty(vals[AccessLogKeyReferer])),
+		strconv.Quote(dashIfEmpty(vals[AccessLogKeyUserAgent])),
+	)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := io.WriteString(h.w, line)
+
+	return err
+}
+
+func (h *combinedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &combinedHandler{
+		w:     h.w,
+		mu:    h.mu,
+		attrs: append(append([]slog.Attr{}, h.attrs...), attrs...),
+	}
+}
+
+func (h *combinedHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
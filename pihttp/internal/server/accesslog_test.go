@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerAccessLog(t *testing.T) {
+	out := new(syncBuffer)
+	s := New(
+		WithHandler(echoPathHandler),
+		WithAccessLog(slog.New(NewAccessLogHandler(out, AccessLogJSON))),
+	)
+	client := serveTestConn(t, s)
+
+	go func() {
+		_, _ = client.Write([]byte(
+			"GET /coffee?size=big HTTP/1.1\r\nHost: localhost:42069\r\nUser-Agent: curl/8.0\r\n\r\n" +
+				"GET /tea HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+		))
+	}()
+
+	clientReader := bufio.NewReader(client)
+	for range 2 {
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		readResponseBody(t, resp)
+	}
+
+	var lines [][]byte
+	require.Eventually(t, func() bool {
+		lines = bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		return len(lines) == 2
+	}, time.Second, time.Millisecond)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &record))
+	assert.Equal(t, "access", record["msg"])
+	assert.Equal(t, "GET", record[AccessLogKeyMethod])
+	assert.Equal(t, "/coffee?size=big", record[AccessLogKeyTarget])
+	assert.Equal(t, "HTTP/1.1", record[AccessLogKeyProto])
+	assert.Equal(t, float64(200), record[AccessLogKeyStatus])
+	assert.Equal(t, float64(len("you asked for /coffee")), record[AccessLogKeyBytes])
+	assert.Contains(t, record, AccessLogKeyDuration)
+	assert.Equal(t, "pipe", record[AccessLogKeyRemoteAddr])
+	assert.Equal(t, "curl/8.0", record[AccessLogKeyUserAgent])
+	assert.Equal(t, "test", record[AccessLogKeyConnID])
+	assert.Equal(t, "test-1", record[AccessLogKeyRequestID])
+
+	require.NoError(t, json.Unmarshal(lines[1], &record))
+	assert.Equal(t, "/tea", record[AccessLogKeyTarget])
+	assert.Equal(t, "test-2", record[AccessLogKeyRequestID])
+}
+
+func TestServerAccessLogErrors(t *testing.T) {
+	out := new(syncBuffer)
+	s := New(
+		WithHandler(echoPathHandler),
+		WithAccessLog(slog.New(NewAccessLogHandler(out, AccessLogJSON))),
+	)
+	client := serveTestConn(t, s)
+	clientReader := bufio.NewReader(client)
+
+	go func() {
+		_, _ = client.Write([]byte(
+			"GET /coffee HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+				"PIZZA / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+		))
+	}()
+
+	resp, err := http.ReadResponse(clientReader, nil)
+	require.NoError(t, err)
+	readResponseBody(t, resp)
+	resp, err = http.ReadResponse(clientReader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	readResponseBody(t, resp)
+	_, err = clientReader.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(lines[1], &record))
+	assert.Equal(t, float64(http.StatusNotImplemented), record[AccessLogKeyStatus])
+	assert.Equal(t, float64(len("501 Not Implemented\n")), record[AccessLogKeyBytes])
+	assert.Equal(t, "", record[AccessLogKeyMethod])
+	assert.Equal(t, "pipe", record[AccessLogKeyRemoteAddr])
+	assert.Equal(t, "test-2", record[AccessLogKeyRequestID])
+}
+
+func TestServerAccessLogLevel(t *testing.T) {
+	out := new(syncBuffer)
+	s := New(
+		WithHandler(echoPathHandler),
+		WithAccessLog(slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	)
+	client := serveTestConn(t, s)
+	clientReader := bufio.NewReader(client)
+
+	go func() {
+		_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\nConnection: close\r\n\r\n"))
+	}()
+
+	resp, err := http.ReadResponse(clientReader, nil)
+	require.NoError(t, err)
+	readResponseBody(t, resp)
+	// the connection is closed after the access is logged
+	_, err = clientReader.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+
+	assert.Empty(t, out.Bytes())
+}
+
+func TestNewAccessLogHandler(t *testing.T) {
+	at := time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+	record := slog.NewRecord(at, slog.LevelInfo, accessLogMsg, 0)
+	record.AddAttrs(
+		slog.String(AccessLogKeyMethod, "GET"),
+		slog.String(AccessLogKeyTarget, "/index.html"),
+		slog.String(AccessLogKeyProto, "HTTP/1.1"),
+		slog.Int(AccessLogKeyStatus, 200),
+		slog.Int(AccessLogKeyBytes, 2326),
+		slog.String(AccessLogKeyRemoteAddr, "127.0.0.1:54321"),
+		slog.String(AccessLogKeyUserAgent, "curl/8.0"),
+		slog.String(AccessLogKeyReferer, ""),
+	)
+
+	t.Run("combined", func(t *testing.T) {
+		out := new(bytes.Buffer)
+
+		err := NewAccessLogHandler(out, AccessLogCombined).Handle(context.Background(), record)
+
+		require.NoError(t, err)
+		assert.Equal(
+			t,
+			"127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] \"GET /index.html HTTP/1.1\" 200 2326 \"-\" \"curl/8.0\"\n",
+			out.String(),
+		)
+	})
+
+	t.Run("combined request not parsed", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		record := slog.NewRecord(at, slog.LevelInfo, accessLogMsg, 0)
+		record.AddAttrs(
+			slog.String(AccessLogKeyMethod, ""),
+			slog.Int(AccessLogKeyStatus, 400),
+			slog.Int(AccessLogKeyBytes, 16),
+			slog.String(AccessLogKeyRemoteAddr, "127.0.0.1:54321"),
+		)
+
+		err := NewAccessLogHandler(out, AccessLogCombined).Handle(context.Background(), record)
+
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] \"-\" 400 16 \"-\" \"-\"\n", out.String())
+	})
+
+	t.Run("logfmt", func(t *testing.T) {
+		out := new(bytes.Buffer)
+
+		err := NewAccessLogHandler(out, AccessLogLogfmt).Handle(context.Background(), record)
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "msg=access method=GET target=/index.html proto=HTTP/1.1 status=200 bytes=2326")
+	})
+}
+
+// syncBuffer is a bytes.Buffer safe to write from the connection goroutine while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buf.Bytes())
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// connReader is the src of the request.Reader of a connection.
+//
+// While the handler runs, once the request body is read, connReader reads the connection in the
+// background to notice the client closing it and cancel the connection context. The byte read in
+// the background, the start of the next pipelined request, is returned on the next Read.
+type connReader struct {
+	conn   net.Conn
+	cancel context.CancelFunc
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// inRead is true while the background read runs, aborted is true when it was asked to stop.
+	inRead  bool
+	aborted bool
+	hasByte bool
+	byteBuf [1]byte
+	// err is the error of the background read, returned on the next Read.
+	err error
+}
+
+func newConnReader(conn net.Conn, cancel context.CancelFunc) *connReader {
+	cr := &connReader{
+		conn:   conn,
+		cancel: cancel,
+	}
+	cr.cond = sync.NewCond(&cr.mu)
+
+	return cr
+}
+
+func (cr *connReader) Read(p []byte) (int, error) {
+	cr.mu.Lock()
+	if cr.inRead {
+		cr.mu.Unlock()
+		panic("server: read on connection with a background read running")
+	}
+
+	if cr.err != nil {
+		err := cr.err
+		cr.err = nil
+		cr.mu.Unlock()
+		return 0, err
+	}
+
+	if cr.hasByte && len(p) > 0 {
+		p[0] = cr.byteBuf[0]
+		cr.hasByte = false
+		cr.mu.Unlock()
+		return 1, nil
+	}
+	cr.mu.Unlock()
+
+	return cr.conn.Read(p)
+}
+
+// startBackgroundRead starts the background read, it must only be called when the request body was read.
+func (cr *connReader) startBackgroundRead() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if cr.inRead || cr.hasByte || cr.err != nil {
+		return
+	}
+	cr.inRead = true
+
+	// the read timeout is for the request, the client can stay quiet while it waits for the response
+	_ = cr.conn.SetReadDeadline(time.Time{})
+	go cr.backgroundRead()
+}
+
+func (cr *connReader) backgroundRead() {
+	n, err := cr.conn.Read(cr.byteBuf[:])
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if n == 1 {
+		cr.hasByte = true
+	}
+
+	if err != nil && !(cr.aborted && errors.Is(err, os.ErrDeadlineExceeded)) {
+		cr.err = err
+		cr.cancel()
+	}
+
+	cr.inRead = false
+	cr.aborted = false
+	cr.cond.Broadcast()
+}
+
+// abortPendingRead stops the background read and waits for it to return.
+// The caller must set the read deadline again before reading.
+func (cr *connReader) abortPendingRead() {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if !cr.inRead {
+		return
+	}
+
+	cr.aborted = true
+	_ = cr.conn.SetReadDeadline(aLongTimeAgo)
+	for cr.inRead {
+		cr.cond.Wait()
+	}
+}
+
+// connWriter is where the responses of a connection are written,
+// a failed write cancels the connection context as no one will receive the response.
+type connWriter struct {
+	conn   net.Conn
+	cancel context.CancelFunc
+}
+
+func (cw *connWriter) Write(p []byte) (int, error) {
+	n, err := cw.conn.Write(p)
+	if err != nil {
+		cw.cancel()
+	}
+
+	return n, err
+}
+
+func (cw *connWriter) Close() error {
+	return cw.conn.Close()
+}
@@ -1,8 +1,42 @@
 package server
 
 import (
+	"fmt"
+
 	"github.com/gpbPiazza/httpfromtcp/internal/request"
 	"github.com/gpbPiazza/httpfromtcp/internal/response"
 )
 
 type Handler func(w *response.Writer, req *request.Request)
+
+// Middleware wraps a Handler to run code before and after it, like logging or authentication.
+// A Middleware can answer the request itself and not call next.
+type Middleware func(next Handler) Handler
+
+// Chain wraps handler with middlewares, the first middleware is the outermost: it is the first to
+// run before handler and the last to run after it. Chain(h, a, b) is a(b(h)).
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// ErrorHandler answers a request that failed to be parsed. The connection is closed after it returns.
+// err.StatusCode is the status code the request must be answered with.
+type ErrorHandler func(w *response.Writer, err *request.ParseError)
+
+// defaultErrorHandler answers with the status code and its reason phrase,
+// the parse error message is internal and is not sent to the client.
+func defaultErrorHandler(w *response.Writer, err *request.ParseError) {
+	writeStatus(w, err.StatusCode)
+}
+
+// writeStatus answers with statusCode and its reason phrase as the body.
+func writeStatus(w *response.Writer, statusCode int) {
+	_ = w.WriteStatusLine(statusCode)
+	body := []byte(fmt.Sprintf("%d %s\n", statusCode, response.ReasonPhrase(statusCode)))
+	_ = w.WriteHeaders(response.DefaultHeaders(len(body)))
+	_, _ = w.WriteBody(body)
+}
@@ -1,7 +1,25 @@
 package server
 
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/request"
+)
+
 type options struct {
-	handler Handler
+	handler           Handler
+	middlewares       []Middleware
+	errorHandler      ErrorHandler
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	requestOptions    []request.Option
+	repanic           bool
+	logger            *slog.Logger
+	accessLogger      *slog.Logger
+	serverName        string
 }
 
 type Option interface {
@@ -21,3 +39,209 @@ type optionWithHandler struct {
 func (o *optionWithHandler) apply(opts *options) {
 	opts.handler = o.handler
 }
+
+// WithMiddleware wraps the handler with middlewares, see Chain for the order they run.
+// It can be used many times, the middlewares of each call are added after the ones already set.
+// The error handler is not wrapped.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return &optionWithMiddleware{
+		middlewares: middlewares,
+	}
+}
+
+type optionWithMiddleware struct {
+	middlewares []Middleware
+}
+
+func (o *optionWithMiddleware) apply(opts *options) {
+	opts.middlewares = append(opts.middlewares, o.middlewares...)
+}
+
+// WithErrorHandler sets the handler that answers requests that failed to be parsed,
+// use it to customize the error response body.
+func WithErrorHandler(handler ErrorHandler) Option {
+	return &optionWithErrorHandler{
+		handler: handler,
+	}
+}
+
+type optionWithErrorHandler struct {
+	handler ErrorHandler
+}
+
+func (o *optionWithErrorHandler) apply(opts *options) {
+	opts.errorHandler = o.handler
+}
+
+// WithIdleTimeout sets how long a keep-alive connection waits for the next request before being closed.
+// A zero or negative timeout means idle connections are never closed by the server.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return &optionWithIdleTimeout{
+		timeout: timeout,
+	}
+}
+
+type optionWithIdleTimeout struct {
+	timeout time.Duration
+}
+
+func (o *optionWithIdleTimeout) apply(opts *options) {
+	opts.idleTimeout = o.timeout
+}
+
+// WithLogger sets the logger of the server events, like connections accepted, requests that failed to be parsed
+// and handler panics. The default is slog.Default.
+func WithLogger(logger *slog.Logger) Option {
+	return &optionWithLogger{
+		logger: logger,
+	}
+}
+
+type optionWithLogger struct {
+	logger *slog.Logger
+}
+
+func (o *optionWithLogger) apply(opts *options) {
+	opts.logger = o.logger
+}
+
+// WithAccessLog logs every request served to logger, with the AccessLogKey attributes. Use NewAccessLogHandler
+// to log in the JSON, logfmt or Apache combined format. By default the access log is disabled.
+func WithAccessLog(logger *slog.Logger) Option {
+	return &optionWithAccessLog{
+		logger: logger,
+	}
+}
+
+type optionWithAccessLog struct {
+	logger *slog.Logger
+}
+
+func (o *optionWithAccessLog) apply(opts *options) {
+	opts.accessLogger = o.logger
+}
+
+// WithServerHeader sets the Server header of every response, see response.WithServerHeader.
+func WithServerHeader(name string) Option {
+	return &optionWithServerHeader{
+		name: name,
+	}
+}
+
+type optionWithServerHeader struct {
+	name string
+}
+
+func (o *optionWithServerHeader) apply(opts *options) {
+	opts.serverName = o.name
+}
+
+// WithRepanic makes a panic in the handler panic again after it is logged and answered, crashing the program.
+// Use it on development to not miss a panic, by default the panic is recovered and only the connection is closed.
+func WithRepanic(repanic bool) Option {
+	return &optionWithRepanic{
+		repanic: repanic,
+	}
+}
+
+type optionWithRepanic struct {
+	repanic bool
+}
+
+func (o *optionWithRepanic) apply(opts *options) {
+	opts.repanic = o.repanic
+}
+
+// WithReadHeaderTimeout sets how long the client has to send the request line and the headers,
+// counting from the first byte of the request. A client that is too slow is answered with
+// response.StatusRequestTimeout. The default is 10 seconds, a zero or negative timeout means no limit.
+func WithReadHeaderTimeout(timeout time.Duration) Option {
+	return &optionWithReadHeaderTimeout{
+		timeout: timeout,
+	}
+}
+
+type optionWithReadHeaderTimeout struct {
+	timeout time.Duration
+}
+
+func (o *optionWithReadHeaderTimeout) apply(opts *options) {
+	opts.readHeaderTimeout = o.timeout
+}
+
+// WithReadTimeout sets how long the client has to send the whole request, body included,
+// counting from the first byte of the request. A body read after the timeout fails with a
+// request.ParseError of kind request.KindRequestTimeout. By default the request read time is not limited.
+func WithReadTimeout(timeout time.Duration) Option {
+	return &optionWithReadTimeout{
+		timeout: timeout,
+	}
+}
+
+type optionWithReadTimeout struct {
+	timeout time.Duration
+}
+
+func (o *optionWithReadTimeout) apply(opts *options) {
+	opts.readTimeout = o.timeout
+}
+
+// WithWriteTimeout sets how long the handler has to write the response, counting from the end
+// of the request headers. A write after the timeout fails and the connection is closed,
+// the request context is done when the timeout expires.
+// By default the response write time is not limited.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return &optionWithWriteTimeout{
+		timeout: timeout,
+	}
+}
+
+type optionWithWriteTimeout struct {
+	timeout time.Duration
+}
+
+func (o *optionWithWriteTimeout) apply(opts *options) {
+	opts.writeTimeout = o.timeout
+}
+
+// WithMaxRequestLineBytes limits the request line length, longer request lines are answered with
+// response.StatusRequestURITooLong. The default is request.DefaultMaxRequestLineBytes.
+func WithMaxRequestLineBytes(n int) Option {
+	return &optionWithRequestOption{
+		opt: request.WithMaxRequestLineBytes(n),
+	}
+}
+
+// WithMaxHeaderBytes limits the header section size, bigger header sections are answered with
+// response.StatusRequestHeaderFieldsTooLarge. The default is request.DefaultMaxHeaderBytes.
+func WithMaxHeaderBytes(n int) Option {
+	return &optionWithRequestOption{
+		opt: request.WithMaxHeaderBytes(n),
+	}
+}
+
+// WithMaxHeaderCount limits the number of header field lines, more field lines are answered with
+// response.StatusRequestHeaderFieldsTooLarge. The default is request.DefaultMaxHeaderCount.
+func WithMaxHeaderCount(n int) Option {
+	return &optionWithRequestOption{
+		opt: request.WithMaxHeaderCount(n),
+	}
+}
+
+// WithMaxBodyBytes limits the body size, a Content-Length bigger than n is answered with
+// response.StatusRequestEntityTooLarge before the handler is called.
+// A chunked body crossing the limit fails the handler Body.Read with request.ErrBodyTooLarge.
+// By default the body size is not limited.
+func WithMaxBodyBytes(n int64) Option {
+	return &optionWithRequestOption{
+		opt: request.WithMaxBodyBytes(n),
+	}
+}
+
+type optionWithRequestOption struct {
+	opt request.Option
+}
+
+func (o *optionWithRequestOption) apply(opts *options) {
+	opts.requestOptions = append(opts.requestOptions, o.opt)
+}
@@ -1,10 +1,15 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/rand"
 	"net"
+	"runtime/debug"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -12,16 +17,47 @@ import (
 	"github.com/gpbPiazza/httpfromtcp/internal/response"
 )
 
+// ErrServerClosed is returned by Serve and ListenAndServe after Shutdown or Close.
+var ErrServerClosed = errors.New("server: Server closed")
+
 type Server struct {
+	// mu guards tcpListener and conns, they are changed by the accept loop, the connections and Shutdown.
+	mu          sync.Mutex
 	tcpListener net.Listener
+	conns       map[net.Conn]connState
 	isClosed    *atomic.Bool
+	// baseCtx is the parent of the request contexts, it is cancelled by Shutdown and Close.
+	baseCtx       context.Context
+	cancelBaseCtx context.CancelFunc
+
+	handler           Handler
+	errorHandler      ErrorHandler
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	requestOptions    []request.Option
+	repanic           bool
+	logger            *slog.Logger
+	accessLogger      *slog.Logger
+	serverName        string
 
-	handler Handler
+	// now is time.Now, replaced on tests to expire deadlines without waiting.
+	now func() time.Time
 }
 
+const (
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultReadHeaderTimeout = 10 * time.Second
+)
+
 func New(opts ...Option) *Server {
 	option := options{
-		handler: nil,
+		handler:           nil,
+		errorHandler:      defaultErrorHandler,
+		idleTimeout:       defaultIdleTimeout,
+		readHeaderTimeout: defaultReadHeaderTimeout,
+		logger:            slog.Default(),
 	}
 
 	for _, opt := range opts {
@@ -31,40 +67,87 @@ func New(opts ...Option) *Server {
 	closed := new(atomic.Bool)
 	closed.Store(false)
 
+	baseCtx, cancelBaseCtx := context.WithCancel(context.Background())
+
 	s := &Server{
-		isClosed: closed,
-		handler:  option.handler,
+		baseCtx:           baseCtx,
+		cancelBaseCtx:     cancelBaseCtx,
+		conns:             make(map[net.Conn]connState),
+		isClosed:          closed,
+		handler:           Chain(option.handler, option.middlewares...),
+		errorHandler:      option.errorHandler,
+		idleTimeout:       option.idleTimeout,
+		readHeaderTimeout: option.readHeaderTimeout,
+		readTimeout:       option.readTimeout,
+		writeTimeout:      option.writeTimeout,
+		requestOptions:    option.requestOptions,
+		repanic:           option.repanic,
+		logger:            option.logger,
+		accessLogger:      option.accessLogger,
+		serverName:        option.serverName,
+		now:               time.Now,
 	}
 
 	return s
 }
 
+// Close stops accepting connections and closes all connections right away, in-flight requests are cut off.
+// See Shutdown to wait for the in-flight requests.
 func (s *Server) Close() error {
-	defer s.isClosed.Store(true)
+	s.isClosed.Store(true)
+	s.cancelBaseCtx()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return s.tcpListener.Close()
+	err := s.closeListener()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+
+	return err
 }
 
-func (s *Server) Listen(address string) {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", address))
+// ListenAndServe listens on the TCP network address addr, like ":42069" or "localhost:42069",
+// and serves the accepted connections, see Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	if s.isClosed.Load() {
+		return ErrServerClosed
+	}
+
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("Server - error on create listener conn err: %s", err)
+		return fmt.Errorf("Server - error on create listener err: %w", err)
 	}
-	s.tcpListener = listener
 
-	log.Printf("starting listener at port: %d", 42069)
+	return s.Serve(listener)
+}
 
-	for {
-		if s.isClosed.Load() {
-			break
-		}
+// Serve accepts connections on l and serves each one on its own goroutine.
+// Serve always returns a non-nil error and closes l. After Shutdown or Close the returned error is ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.tcpListener = l
+	s.mu.Unlock()
+	if s.isClosed.Load() {
+		// Close or Shutdown was called before the listener was set
+		_ = l.Close()
+		return ErrServerClosed
+	}
+
+	s.logger.Info("starting listener", "addr", l.Addr().String())
 
-		conn, err := s.tcpListener.Accept()
+	for {
+		conn, err := l.Accept()
 		if err != nil {
-			log.Fatalf("Server - error on accept conn err: %s", err)
+			if s.isClosed.Load() {
+				return ErrServerClosed
+			}
+			_ = l.Close()
+			return fmt.Errorf("Server - error on accept conn err: %w", err)
 		}
 		connID := newID()
-		log.Printf("conn ID: %s - conn accepted", connID)
+		s.logger.Debug("conn accepted", "conn_id", connID)
 
 		go s.handleConn(conn, connID)
 	}
@@ -75,23 +158,272 @@ func newID() string {
 	return fmt.Sprintf("%d", newRand.Int63())
 }
 
+// handleConn serves requests from conn one after another until the client or the handler
+// asks to close the connection, the response can not be delimited or the connection stays idle
+// longer than the idle timeout.
+//
+// Once the first byte of a request is read the read header and read timeouts start, a client sending
+// the request too slowly is answered with 408 Request Timeout. The write timeout starts when the
+// request headers are read.
+//
+// A pipelining client can send many requests before reading any response, they are kept in the
+// request.Reader buffer and served one at a time, so the responses are written in request order.
+//
+// While the server shuts down the connection is closed after the in-flight request is answered.
 func (s *Server) handleConn(conn net.Conn, connID string) {
+	if !s.trackConn(conn) {
+		_ = conn.Close()
+		return
+	}
+
 	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Printf("conn ID: %s - error o closing conn err: %s", err, connID)
+		s.untrackConn(conn)
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			s.logger.Warn("error closing conn", "conn_id", connID, "err", err)
 		}
-		log.Printf("conn ID: %s - conn closed", connID)
+		s.logger.Debug("conn closed", "conn_id", connID)
 	}()
 
-	request, err := request.ParseFromReader(conn)
-	resp := response.NewWriter(conn)
-	if err != nil {
-		_ = resp.WriteStatusLine(response.StatusBadRequest)
-		body := []byte(fmt.Sprintf("Error parsing request: %v", err))
-		_ = resp.WriteHeaders(response.DefaultHeaders(len(body)))
-		_, _ = resp.WriteBody(body)
-		return
+	connCtx, cancelConnCtx := context.WithCancel(s.baseCtx)
+	defer cancelConnCtx()
+
+	connReader := newConnReader(conn, cancelConnCtx)
+	connWriter := &connWriter{conn: conn, cancel: cancelConnCtx}
+	reader := request.NewReader(connReader, s.requestOptions...)
+	requestCount := 0
+
+	for {
+		connReader.abortPendingRead()
+		_ = conn.SetReadDeadline(s.deadline(s.idleTimeout))
+
+		if !s.setConnState(conn, connStateIdle) {
+			return
+		}
+
+		if err := reader.WaitForRequest(); err != nil {
+			// the client closed the connection, it was idle for too long or it is broken,
+			// in any case there is no one to answer.
+			return
+		}
+		s.setConnState(conn, connStateActive)
+		readStart := s.now()
+
+		readHeaderDeadline := s.deadline(s.readHeaderTimeout)
+		readDeadline := s.deadline(s.readTimeout)
+		if readHeaderDeadline.IsZero() || (!readDeadline.IsZero() && readDeadline.Before(readHeaderDeadline)) {
+			readHeaderDeadline = readDeadline
+		}
+		_ = conn.SetReadDeadline(readHeaderDeadline)
+
+		req, err := reader.ReadRequest()
+		writeDeadline := s.deadline(s.writeTimeout)
+		_ = conn.SetWriteDeadline(writeDeadline)
+		if err != nil {
+			var parseErr *request.ParseError
+			if !errors.As(err, &parseErr) {
+				return
+			}
+
+			s.logger.Info("error parsing request", "conn_id", connID, "kind", parseErr.Kind, "err", parseErr)
+			resp := s.newResponseWriter(connWriter, response.WithKeepAlive(false))
+			s.errorHandler(resp, parseErr)
+
+			requestCount++
+			s.logAccess(accessLogEntry{
+				resp:       resp,
+				start:      readStart,
+				duration:   s.now().Sub(readStart),
+				remoteAddr: conn.RemoteAddr().String(),
+				connID:     connID,
+				requestID:  fmt.Sprintf("%s-%d", connID, requestCount),
+			})
+			return
+		}
+		_ = conn.SetReadDeadline(readDeadline)
+
+		respOpts := []response.Option{
+			response.WithKeepAlive(keepAlive(req) && !s.isClosed.Load()),
+			response.WithRequestVersion(req.RequestLine.ProtoMajor, req.RequestLine.ProtoMinor),
+		}
+		if req.RequestLine.Method == request.MethodHead {
+			respOpts = append(respOpts, response.WithHeadRequest())
+		}
+		if req.ExpectContinue() && req.ContentLength != 0 {
+			respOpts = append(respOpts, response.WithExpectContinue())
+		}
+
+		reqCtx, cancelReqCtx := context.WithCancel(connCtx)
+		if !writeDeadline.IsZero() {
+			// past the write deadline the response can not be sent, the handler can stop its work
+			reqCtx, cancelReqCtx = context.WithDeadline(connCtx, writeDeadline)
+		}
+		req = req.WithContext(reqCtx)
+		req.RemoteAddr = conn.RemoteAddr().String()
+		req.ConnID = connID
+
+		resp := s.newResponseWriter(connWriter, respOpts...)
+		req.Body = &handlerBody{body: req.Body, resp: resp, onEOF: connReader.startBackgroundRead}
+		if req.ContentLength == 0 {
+			connReader.startBackgroundRead()
+		}
+
+		requestCount++
+		entry := accessLogEntry{
+			req:        req,
+			resp:       resp,
+			start:      readStart,
+			remoteAddr: req.RemoteAddr,
+			connID:     connID,
+			requestID:  fmt.Sprintf("%s-%d", connID, requestCount),
+		}
+		closeConn := s.serveRequest(connWriter, entry)
+		cancelReqCtx()
+		if closeConn {
+			return
+		}
+
+		if !resp.KeepAlive() || s.isClosed.Load() {
+			return
+		}
+
+		// the body not read by the handler must be discarded before the next request
+		if err := req.Body.Close(); err != nil {
+			s.logger.Warn("error discarding request body", "conn_id", connID, "err", err)
+			return
+		}
+	}
+}
+
+// serveRequest calls the handler and logs the access, serveRequest reports if the connection must be closed.
+//
+// A handler panic is recovered, if the handler panics before the response is committed the request is answered
+// with 500 Internal Server Error, otherwise the response is cut off and the connection must be closed so
+// the client does not take it as complete.
+func (s *Server) serveRequest(conn io.WriteCloser, entry accessLogEntry) (closeConn bool) {
+	body, _ := entry.req.Body.(*handlerBody)
+
+	defer func() {
+		v := recover()
+		if v != nil {
+			closeConn = true
+			s.logger.Error(
+				"panic serving request",
+				"conn_id", entry.connID,
+				"request_id", entry.requestID,
+				"panic", v,
+				"stack", string(debug.Stack()),
+			)
+			if !entry.resp.Committed() {
+				entry.resp = s.newErrorResponseWriter(conn, entry.req)
+				writeStatus(entry.resp, response.StatusInternalServerError)
+			}
+		}
+
+		entry.duration = s.now().Sub(entry.start)
+		s.logAccess(entry)
+
+		if v != nil && s.repanic {
+			_ = conn.Close()
+			panic(v)
+		}
+	}()
+
+	s.handler(entry.resp, entry.req)
+
+	var parseErr *request.ParseError
+	if body != nil && entry.resp.Status() == 0 && errors.As(body.err, &parseErr) {
+		// the body was invalid or not received in time and the handler did not answer,
+		// the client is still waiting for a response.
+		s.logger.Info("error reading request body", "conn_id", entry.connID, "kind", parseErr.Kind, "err", parseErr)
+		entry.resp = s.newErrorResponseWriter(conn, entry.req)
+		s.errorHandler(entry.resp, parseErr)
+		return true
+	}
+
+	if err := entry.resp.Finish(); err != nil {
+		s.logger.Error("error finishing response", "conn_id", entry.connID, "request_id", entry.requestID, "err", err)
+		if !entry.resp.Committed() {
+			entry.resp = s.newErrorResponseWriter(conn, entry.req)
+			writeStatus(entry.resp, response.StatusInternalServerError)
+		}
+		return true
+	}
+
+	return false
+}
+
+// newResponseWriter returns a response.Writer to w with the server wide response options.
+func (s *Server) newResponseWriter(w io.Writer, opts ...response.Option) *response.Writer {
+	if s.serverName != "" {
+		opts = append(opts, response.WithServerHeader(s.serverName))
+	}
+
+	return response.NewWriter(w, opts...)
+}
+
+// newErrorResponseWriter returns a response.Writer replacing the one given to the handler of req,
+// to answer with an error status. The connection is closed after the response.
+func (s *Server) newErrorResponseWriter(w io.Writer, req *request.Request) *response.Writer {
+	opts := []response.Option{response.WithKeepAlive(false)}
+	if req.RequestLine.Method == request.MethodHead {
+		opts = append(opts, response.WithHeadRequest())
+	}
+
+	return s.newResponseWriter(w, opts...)
+}
+
+// deadline returns the deadline timeout from now, or the zero time, meaning no deadline,
+// if timeout is zero or negative.
+func (s *Server) deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return s.now().Add(timeout)
+}
+
+// handlerBody is the Request.Body given to the handler. It keeps the body read error,
+// so the server can answer a bad body the handler did not answer.
+//
+// To a client that sent Expect: 100-continue the 100 Continue interim response is sent the first
+// time the handler reads the body. A handler that answers without reading the body, like a 417 or 413,
+// rejects the body before the client sends it.
+//
+// onEOF is called once the body is read to the end.
+type handlerBody struct {
+	body  io.ReadCloser
+	resp  *response.Writer
+	err   error
+	onEOF func()
+}
+
+func (b *handlerBody) Read(p []byte) (int, error) {
+	if err := b.resp.WriteContinue(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.body.Read(p)
+	if errors.Is(err, io.EOF) && b.onEOF != nil {
+		b.onEOF()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		b.err = err
+	}
+
+	return n, err
+}
+
+func (b *handlerBody) Close() error {
+	return b.body.Close()
+}
+
+// keepAlive reports if the client allows the connection to be reused after req.
+// HTTP/1.1 connections are persistent unless the client sends Connection: close,
+// HTTP/1.0 connections are only persistent when the client sends Connection: keep-alive.
+func keepAlive(req *request.Request) bool {
+	if !req.ProtoAtLeast(1, 1) {
+		return req.Headers.HasToken("Connection", "keep-alive")
 	}
 
-	s.handler(resp, request)
+	return !req.Headers.HasToken("Connection", "close")
 }
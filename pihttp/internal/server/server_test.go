@@ -0,0 +1,1183 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gpbPiazza/httpfromtcp/internal/request"
+	"github.com/gpbPiazza/httpfromtcp/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerKeepAlive(t *testing.T) {
+	t.Run("sequential requests are served on the same connection", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+		clientReader := bufio.NewReader(client)
+
+		for _, path := range []string{"/first", "/second"} {
+			_, err := client.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+			require.NoError(t, err)
+
+			resp, err := http.ReadResponse(clientReader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "you asked for "+path, readResponseBody(t, resp))
+			assert.False(t, resp.Close)
+		}
+	})
+
+	t.Run("connection is closed after a request with Connection: close", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\nConnection: close\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /", readResponseBody(t, resp))
+		assert.True(t, resp.Close)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("connection is closed after a HTTP/1.0 request without keep-alive", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /", readResponseBody(t, resp))
+		assert.True(t, resp.Close)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("connection idle after a response is closed", func(t *testing.T) {
+		clock := &fakeClock{}
+		handler := func(w *response.Writer, req *request.Request) {
+			// the idle deadline set after this response is already expired
+			clock.shift(-time.Minute)
+			echoPathHandler(w, req)
+		}
+		s := New(WithHandler(handler), WithIdleTimeout(time.Minute))
+		s.now = clock.now
+		client := serveTestConn(t, s)
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /", readResponseBody(t, resp))
+		assert.False(t, resp.Close)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestServerPipelining(t *testing.T) {
+	t.Run("responses are written in request order", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte(
+				"GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+					"POST /second HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello" +
+					"GET /third HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			))
+		}()
+
+		clientReader := bufio.NewReader(client)
+		for _, path := range []string{"/first", "/second", "/third"} {
+			resp, err := http.ReadResponse(clientReader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "you asked for "+path, readResponseBody(t, resp))
+		}
+	})
+
+	t.Run("connection is closed after the request with Connection: close", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte(
+				"GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+					"GET /second HTTP/1.1\r\nHost: localhost:42069\r\nConnection: close\r\n\r\n" +
+					"GET /third HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			))
+		}()
+
+		clientReader := bufio.NewReader(client)
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /first", readResponseBody(t, resp))
+		assert.False(t, resp.Close)
+
+		resp, err = http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /second", readResponseBody(t, resp))
+		assert.True(t, resp.Close)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("parse error in a pipelined request is answered after the previous responses", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte(
+				"GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+					"PIZZA /second HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			))
+		}()
+
+		clientReader := bufio.NewReader(client)
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /first", readResponseBody(t, resp))
+
+		resp, err = http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+		assert.True(t, resp.Close)
+	})
+}
+
+func TestServerErrorHandler(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       string
+		statusCode int
+		kind       request.ErrorKind
+	}{
+		{
+			name:       "malformed request line",
+			data:       "GET /\r\nHost: localhost:42069\r\n\r\n",
+			statusCode: http.StatusBadRequest,
+			kind:       request.KindMalformedRequestLine,
+		},
+		{
+			name:       "host sent more than once",
+			data:       "GET / HTTP/1.1\r\nHost: localhost:42069\r\nHost: evil.com\r\n\r\n",
+			statusCode: http.StatusBadRequest,
+			kind:       request.KindMalformedHeaders,
+		},
+		{
+			name:       "method not implemented",
+			data:       "PIZZA / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			statusCode: http.StatusNotImplemented,
+			kind:       request.KindMethodNotImplemented,
+		},
+		{
+			name:       "http version not supported",
+			data:       "GET / HTTP/2.0\r\nHost: localhost:42069\r\n\r\n",
+			statusCode: http.StatusHTTPVersionNotSupported,
+			kind:       request.KindVersionNotSupported,
+		},
+		{
+			name:       "length required",
+			data:       "POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+			statusCode: http.StatusLengthRequired,
+			kind:       request.KindLengthRequired,
+		},
+	}
+
+	customErrorHandler := func(w *response.Writer, err *request.ParseError) {
+		body := []byte(fmt.Sprintf("custom %s", err.Kind))
+		_ = w.WriteStatusLine(err.StatusCode)
+		h := response.DefaultHeaders(len(body))
+		h.Set("X-Error-Kind", string(err.Kind))
+		_ = w.WriteHeaders(h)
+		_, _ = w.WriteBody(body)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := serveTestConn(t, New(WithHandler(echoPathHandler), WithErrorHandler(customErrorHandler)))
+			clientReader := bufio.NewReader(client)
+
+			go func() {
+				_, _ = client.Write([]byte(tc.data))
+			}()
+
+			resp, err := http.ReadResponse(clientReader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.statusCode, resp.StatusCode)
+			assert.Equal(t, string(tc.kind), resp.Header.Get("X-Error-Kind"))
+			assert.Equal(t, "custom "+string(tc.kind), readResponseBody(t, resp))
+			assert.True(t, resp.Close)
+
+			_, err = clientReader.ReadByte()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+
+	t.Run("default error handler answers the status and reason phrase", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/2.0\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusHTTPVersionNotSupported, resp.StatusCode)
+		assert.Equal(t, "505 HTTP Version Not Supported\n", readResponseBody(t, resp))
+	})
+}
+
+func TestServerLimits(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opt        Option
+		data       string
+		statusCode int
+	}{
+		{
+			name:       "request line too long",
+			opt:        WithMaxRequestLineBytes(32),
+			data:       "GET /" + strings.Repeat("a", 64) + " HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			statusCode: http.StatusRequestURITooLong,
+		},
+		{
+			name:       "header section too large",
+			opt:        WithMaxHeaderBytes(64),
+			data:       "GET / HTTP/1.1\r\nHost: localhost:42069\r\nX-Gremio: " + strings.Repeat("a", 64) + "\r\n\r\n",
+			statusCode: http.StatusRequestHeaderFieldsTooLarge,
+		},
+		{
+			name:       "too many header field lines",
+			opt:        WithMaxHeaderCount(2),
+			data:       "GET / HTTP/1.1\r\nHost: localhost:42069\r\nX-A: 1\r\nX-B: 2\r\n\r\n",
+			statusCode: http.StatusRequestHeaderFieldsTooLarge,
+		},
+		{
+			name:       "content length too large",
+			opt:        WithMaxBodyBytes(4),
+			data:       "POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello",
+			statusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			handler := func(w *response.Writer, req *request.Request) {
+				called = true
+				echoPathHandler(w, req)
+			}
+			client := serveTestConn(t, New(WithHandler(handler), tc.opt))
+			clientReader := bufio.NewReader(client)
+
+			go func() {
+				_, _ = client.Write([]byte(tc.data))
+			}()
+
+			resp, err := http.ReadResponse(clientReader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.statusCode, resp.StatusCode)
+			assert.True(t, resp.Close)
+			assert.False(t, called)
+		})
+	}
+}
+
+func TestServerExpectContinue(t *testing.T) {
+	echoBodyHandler := func(w *response.Writer, req *request.Request) {
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		_ = w.WriteStatusLine(response.StatusOK)
+		_ = w.WriteHeaders(response.DefaultHeaders(len(body)))
+		_, _ = w.WriteBody(body)
+	}
+
+	t.Run("100 Continue is sent when the handler reads the body", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoBodyHandler)))
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte(
+			"POST /upload HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n",
+		))
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusContinue, resp.StatusCode)
+
+		go func() {
+			_, _ = client.Write([]byte("hello"))
+		}()
+
+		resp, err = http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "hello", readResponseBody(t, resp))
+		assert.False(t, resp.Close)
+	})
+
+	t.Run("handler rejects the body without reading it", func(t *testing.T) {
+		rejectHandler := func(w *response.Writer, req *request.Request) {
+			_ = w.WriteStatusLine(response.StatusRequestEntityTooLarge)
+			_ = w.WriteHeaders(response.DefaultHeaders(0))
+		}
+		client := serveTestConn(t, New(WithHandler(rejectHandler)))
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte(
+			"POST /upload HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5000\r\nExpect: 100-continue\r\n\r\n",
+		))
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		assert.True(t, resp.Close)
+	})
+
+	t.Run("unknown expectation is answered with 417", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoBodyHandler)))
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte(
+			"POST /upload HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\nExpect: 200-ok\r\n\r\n",
+		))
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusExpectationFailed, resp.StatusCode)
+		assert.True(t, resp.Close)
+	})
+}
+
+func TestServerShutdown(t *testing.T) {
+	t.Run("idle connection is closed", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+		client := serveTestConn(t, s)
+		waitConnState(t, s, connStateIdle)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := s.Shutdown(ctx)
+
+		require.NoError(t, err)
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("in-flight request is answered before the connection is closed", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		blockingHandler := func(w *response.Writer, req *request.Request) {
+			close(started)
+			<-release
+			echoPathHandler(w, req)
+		}
+		s := New(WithHandler(blockingHandler))
+		client := serveTestConn(t, s)
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+		<-started
+
+		shutdownErr := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			shutdownErr <- s.Shutdown(ctx)
+		}()
+
+		select {
+		case <-shutdownErr:
+			t.Fatal("Shutdown returned before the in-flight request was answered")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(release)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /slow", readResponseBody(t, resp))
+		assert.NoError(t, <-shutdownErr)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("connections are closed when the context expires", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		blockingHandler := func(w *response.Writer, req *request.Request) {
+			close(started)
+			<-release
+		}
+		s := New(WithHandler(blockingHandler))
+		client := serveTestConn(t, s)
+		t.Cleanup(func() { close(release) })
+
+		_, err := client.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = s.Shutdown(ctx)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("connection accepted after shutdown is not served", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+		require.NoError(t, s.Shutdown(context.Background()))
+
+		client := serveTestConn(t, s)
+
+		_, err := client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestServerServe(t *testing.T) {
+	t.Run("serves connections until shutdown", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- s.Serve(listener)
+		}()
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/coffee", listener.Addr()))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "you asked for /coffee", readResponseBody(t, resp))
+		http.DefaultClient.CloseIdleConnections()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, s.Shutdown(ctx))
+
+		assert.ErrorIs(t, <-serveErr, ErrServerClosed)
+		_, err = net.Dial("tcp", listener.Addr().String())
+		assert.Error(t, err)
+	})
+
+	t.Run("serve after shutdown", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+		require.NoError(t, s.Shutdown(context.Background()))
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		err = s.Serve(listener)
+
+		assert.ErrorIs(t, err, ErrServerClosed)
+		_, err = listener.Accept()
+		assert.ErrorIs(t, err, net.ErrClosed)
+	})
+
+	t.Run("listen error is returned", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+
+		err := s.ListenAndServe("not an address")
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrServerClosed)
+	})
+
+	t.Run("accept error is returned", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+		acceptErr := errors.New("accept failed")
+
+		err := s.Serve(&failingListener{err: acceptErr})
+
+		assert.ErrorIs(t, err, acceptErr)
+	})
+}
+
+func TestServerTimeouts(t *testing.T) {
+	t.Run("slow request headers are answered with 408", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler), WithIdleTimeout(time.Hour), WithReadHeaderTimeout(time.Minute))
+		s.now = minuteAgo().now
+		client := serveTestConn(t, s)
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte("GET /slow HTTP/1.1\r\nHost: local"))
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+		assert.True(t, resp.Close)
+	})
+
+	t.Run("slow request body not answered by the handler is answered with 408", func(t *testing.T) {
+		readBodyHandler := func(w *response.Writer, req *request.Request) {
+			_, err := io.ReadAll(req.Body)
+			assert.ErrorIs(t, err, request.ErrRequestTimeout)
+		}
+		s := New(WithHandler(readBodyHandler), WithIdleTimeout(time.Hour), WithReadTimeout(time.Minute))
+		s.now = minuteAgo().now
+		client := serveTestConn(t, s)
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte("POST /upload HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\n"))
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+		assert.True(t, resp.Close)
+	})
+
+	t.Run("idle connection is closed without a response", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler), WithIdleTimeout(time.Minute))
+		s.now = minuteAgo().now
+		client := serveTestConn(t, s)
+
+		_, err := client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("connection is active once the first byte of the request is read", func(t *testing.T) {
+		s := New(WithHandler(echoPathHandler))
+		client := serveTestConn(t, s)
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte("GET /slow HTTP/1.1\r\n"))
+		require.NoError(t, err)
+		waitConnState(t, s, connStateActive)
+		_, err = client.Write([]byte("Host: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /slow", readResponseBody(t, resp))
+	})
+
+	t.Run("context is done when the write timeout expires before the handler writes", func(t *testing.T) {
+		ctxErr := make(chan error, 1)
+		waitHandler := func(w *response.Writer, req *request.Request) {
+			select {
+			case <-req.Context().Done():
+				ctxErr <- req.Context().Err()
+			case <-time.After(time.Second):
+				ctxErr <- nil
+			}
+		}
+		s := New(WithHandler(waitHandler), WithIdleTimeout(time.Hour), WithWriteTimeout(time.Minute))
+		s.now = minuteAgo().now
+		client := serveTestConn(t, s)
+
+		_, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+
+		assert.ErrorIs(t, <-ctxErr, context.DeadlineExceeded)
+	})
+
+	t.Run("slow response write fails and closes the connection", func(t *testing.T) {
+		writeErr := make(chan error, 1)
+		writeHandler := func(w *response.Writer, req *request.Request) {
+			writeErr <- w.WriteStatusLine(response.StatusOK)
+		}
+		s := New(WithHandler(writeHandler), WithIdleTimeout(time.Hour), WithWriteTimeout(time.Minute))
+		s.now = minuteAgo().now
+		client := serveTestConn(t, s)
+
+		_, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+
+		assert.ErrorContains(t, <-writeErr, "i/o timeout")
+		_, err = client.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestServerMiddleware(t *testing.T) {
+	t.Run("middlewares run in the order they are set", func(t *testing.T) {
+		var calls []string
+		record := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return func(w *response.Writer, req *request.Request) {
+					calls = append(calls, name+" before")
+					next(w, req)
+					calls = append(calls, name+" after")
+				}
+			}
+		}
+		handler := func(w *response.Writer, req *request.Request) {
+			calls = append(calls, "handler")
+			echoPathHandler(w, req)
+		}
+		done := make(chan struct{})
+		waitDone := func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				next(w, req)
+				close(done)
+			}
+		}
+		s := New(
+			WithHandler(handler),
+			WithMiddleware(waitDone, record("first"), record("second")),
+			WithMiddleware(record("third")),
+		)
+		client := serveTestConn(t, s)
+
+		go func() {
+			_, _ = client.Write([]byte("GET /coffee HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /coffee", readResponseBody(t, resp))
+		<-done
+		assert.Equal(t, []string{
+			"first before",
+			"second before",
+			"third before",
+			"handler",
+			"third after",
+			"second after",
+			"first after",
+		}, calls)
+	})
+
+	t.Run("middleware observes the status and bytes written", func(t *testing.T) {
+		type written struct {
+			status int
+			bytes  int
+		}
+		observed := make(chan written, 1)
+		observe := func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				next(w, req)
+				observed <- written{status: w.Status(), bytes: w.BytesWritten()}
+			}
+		}
+		s := New(WithHandler(echoPathHandler), WithMiddleware(observe))
+		client := serveTestConn(t, s)
+
+		go func() {
+			_, _ = client.Write([]byte("GET /coffee HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /coffee", readResponseBody(t, resp))
+		assert.Equal(t, written{status: 200, bytes: len("you asked for /coffee")}, <-observed)
+	})
+
+	t.Run("middleware observes the status and bytes of a response written with write", func(t *testing.T) {
+		type written struct {
+			status    int
+			bytes     int
+			committed bool
+		}
+		observed := make(chan written, 1)
+		observe := func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				next(w, req)
+				observed <- written{status: w.Status(), bytes: w.BytesWritten(), committed: w.Committed()}
+			}
+		}
+		handler := func(w *response.Writer, req *request.Request) {
+			w.WriteHeader(response.StatusCreated)
+			_, _ = w.Write([]byte("hello"))
+		}
+		s := New(WithHandler(handler), WithMiddleware(observe))
+		client := serveTestConn(t, s)
+
+		go func() {
+			_, _ = client.Write([]byte("POST /coffee HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 0\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "hello", readResponseBody(t, resp))
+		assert.Equal(t, written{status: 201, bytes: len("hello"), committed: false}, <-observed)
+	})
+
+	t.Run("middleware answers without calling the handler", func(t *testing.T) {
+		deny := func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				_ = w.WriteStatusLine(response.StatusForbidden)
+				_ = w.WriteHeaders(response.DefaultHeaders(0))
+			}
+		}
+		s := New(WithHandler(echoPathHandler), WithMiddleware(deny))
+		client := serveTestConn(t, s)
+
+		go func() {
+			_, _ = client.Write([]byte("GET /coffee HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestServerPanicRecovery(t *testing.T) {
+	t.Run("panic before the status line is answered with 500", func(t *testing.T) {
+		panicHandler := func(w *response.Writer, req *request.Request) {
+			panic("gremio")
+		}
+		client := serveTestConn(t, New(WithHandler(panicHandler)))
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, "500 Internal Server Error\n", readResponseBody(t, resp))
+		assert.True(t, resp.Close)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("panic in the middle of the body closes the connection", func(t *testing.T) {
+		panicHandler := func(w *response.Writer, req *request.Request) {
+			_ = w.WriteStatusLine(response.StatusOK)
+			_ = w.WriteHeaders(response.DefaultHeaders(10))
+			_, _ = w.WriteBody([]byte("vamo"))
+			panic("gremio")
+		}
+		client := serveTestConn(t, New(WithHandler(panicHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		assert.Equal(t, "vamo", string(body))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("panic again with repanic", func(t *testing.T) {
+		panicHandler := func(w *response.Writer, req *request.Request) {
+			panic("gremio")
+		}
+		s := New(WithHandler(panicHandler), WithRepanic(true))
+		client, conn := net.Pipe()
+		defer client.Close()
+
+		respStatus := make(chan int, 1)
+		go func() {
+			resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+			if assert.NoError(t, err) {
+				_, _ = io.ReadAll(resp.Body)
+				respStatus <- resp.StatusCode
+			}
+		}()
+
+		req, err := request.ParseFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+
+		assert.PanicsWithValue(t, "gremio", func() {
+			s.serveRequest(conn, accessLogEntry{req: req, resp: response.NewWriter(conn), connID: "test"})
+		})
+		assert.Equal(t, http.StatusInternalServerError, <-respStatus)
+	})
+}
+
+func TestServerRequestContext(t *testing.T) {
+	// waitCtxHandler reports the request context error once it is cancelled, without answering.
+	waitCtxHandler := func(ctxErr chan<- error) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			_, _ = io.ReadAll(req.Body)
+			select {
+			case <-req.Context().Done():
+				ctxErr <- req.Context().Err()
+			case <-time.After(time.Second):
+				ctxErr <- nil
+			}
+		}
+	}
+
+	t.Run("request has the remote address and the connection ID", func(t *testing.T) {
+		type reqInfo struct {
+			remoteAddr string
+			connID     string
+			ctx        context.Context
+		}
+		info := make(chan reqInfo, 1)
+		handler := func(w *response.Writer, req *request.Request) {
+			info <- reqInfo{remoteAddr: req.RemoteAddr, connID: req.ConnID, ctx: req.Context()}
+			echoPathHandler(w, req)
+		}
+		client := serveTestConn(t, New(WithHandler(handler)))
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		readResponseBody(t, resp)
+
+		got := <-info
+		assert.Equal(t, "pipe", got.remoteAddr)
+		assert.Equal(t, "test", got.connID)
+		assert.Eventually(t, func() bool {
+			return got.ctx.Err() != nil
+		}, time.Second, time.Millisecond, "request context is cancelled after the response")
+	})
+
+	t.Run("context is cancelled when the client closes the connection", func(t *testing.T) {
+		ctxErr := make(chan error, 1)
+		client := serveTestConn(t, New(WithHandler(waitCtxHandler(ctxErr))))
+
+		_, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+		require.NoError(t, client.Close())
+
+		assert.ErrorIs(t, <-ctxErr, context.Canceled)
+	})
+
+	t.Run("context is cancelled when the client closes the connection after the body", func(t *testing.T) {
+		ctxErr := make(chan error, 1)
+		client := serveTestConn(t, New(WithHandler(waitCtxHandler(ctxErr))))
+
+		_, err := client.Write([]byte("POST / HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello"))
+		require.NoError(t, err)
+		require.NoError(t, client.Close())
+
+		assert.ErrorIs(t, <-ctxErr, context.Canceled)
+	})
+
+	t.Run("context is cancelled on shutdown", func(t *testing.T) {
+		ctxErr := make(chan error, 1)
+		s := New(WithHandler(waitCtxHandler(ctxErr)))
+		client := serveTestConn(t, s)
+
+		_, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+		waitConnState(t, s, connStateActive)
+		go func() {
+			_ = s.Shutdown(context.Background())
+		}()
+
+		assert.ErrorIs(t, <-ctxErr, context.Canceled)
+	})
+
+	t.Run("request sent while the handler runs is served", func(t *testing.T) {
+		release := make(chan struct{})
+		handler := func(w *response.Writer, req *request.Request) {
+			if req.URL.Path == "/first" {
+				<-release
+			}
+			echoPathHandler(w, req)
+		}
+		client := serveTestConn(t, New(WithHandler(handler)))
+		clientReader := bufio.NewReader(client)
+
+		_, err := client.Write([]byte("GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		require.NoError(t, err)
+		go func() {
+			_, _ = client.Write([]byte("GET /second HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+		// give the background read the time to read the first byte of the second request
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		for _, path := range []string{"/first", "/second"} {
+			resp, err := http.ReadResponse(clientReader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "you asked for "+path, readResponseBody(t, resp))
+		}
+	})
+}
+
+func TestServerDateAndServerHeaders(t *testing.T) {
+	t.Run("date and server headers are sent", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler), WithServerHeader("pinet")))
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), date, 2*time.Second)
+		assert.Equal(t, "pinet", resp.Header.Get("Server"))
+	})
+
+	t.Run("server header is not sent by default", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.Header.Get("Date"))
+		assert.Empty(t, resp.Header.Values("Server"))
+	})
+
+	t.Run("headers set by the handler are kept", func(t *testing.T) {
+		handler := func(w *response.Writer, req *request.Request) {
+			h := response.DefaultHeaders(0)
+			h.Set("Date", "Sun, 06 Nov 1994 08:49:37 GMT")
+			h.Set("Server", "gremio")
+			_ = w.WriteStatusLine(response.StatusOK)
+			_ = w.WriteHeaders(h)
+		}
+		client := serveTestConn(t, New(WithHandler(handler), WithServerHeader("pinet")))
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Sun, 06 Nov 1994 08:49:37 GMT", resp.Header.Get("Date"))
+		assert.Equal(t, "gremio", resp.Header.Get("Server"))
+	})
+
+	t.Run("error responses have the headers", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler), WithServerHeader("pinet")))
+
+		go func() {
+			_, _ = client.Write([]byte("PIZZA / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("Date"))
+		assert.Equal(t, "pinet", resp.Header.Get("Server"))
+	})
+}
+
+func TestServerBufferedResponse(t *testing.T) {
+	t.Run("response written with write is sent after the handler returns", func(t *testing.T) {
+		handler := func(w *response.Writer, req *request.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("you asked for "))
+			_, _ = w.Write([]byte(req.URL.Path))
+		}
+		client := serveTestConn(t, New(WithHandler(handler)))
+
+		go func() {
+			_, _ = client.Write([]byte(
+				"GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+					"GET /second HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			))
+		}()
+
+		clientReader := bufio.NewReader(client)
+		for _, path := range []string{"/first", "/second"} {
+			resp, err := http.ReadResponse(clientReader, nil)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, int64(len("you asked for "+path)), resp.ContentLength)
+			assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "you asked for "+path, readResponseBody(t, resp))
+			assert.False(t, resp.Close)
+		}
+	})
+
+	t.Run("handler writing nothing is answered with 200 and an empty body", func(t *testing.T) {
+		handler := func(w *response.Writer, req *request.Request) {}
+		client := serveTestConn(t, New(WithHandler(handler)))
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(0), resp.ContentLength)
+		assert.Empty(t, readResponseBody(t, resp))
+		assert.False(t, resp.Close)
+	})
+
+	t.Run("response with an invalid header is answered with 500", func(t *testing.T) {
+		handler := func(w *response.Writer, req *request.Request) {
+			w.Header().Set("Location", req.URL.Path+"\r\nSet-Cookie: admin=true")
+			w.WriteHeader(response.StatusFound)
+		}
+		client := serveTestConn(t, New(WithHandler(handler)))
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Empty(t, resp.Header.Values("Set-Cookie"))
+		assert.Equal(t, "500 Internal Server Error\n", readResponseBody(t, resp))
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestServerHeadRequest(t *testing.T) {
+	t.Run("HEAD response has the GET headers and no body", func(t *testing.T) {
+		client := serveTestConn(t, New(WithHandler(echoPathHandler)))
+
+		go func() {
+			_, _ = client.Write([]byte(
+				"HEAD /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+					"GET /second HTTP/1.1\r\nHost: localhost:42069\r\n\r\n",
+			))
+		}()
+
+		clientReader := bufio.NewReader(client)
+		resp, err := http.ReadResponse(clientReader, &http.Request{Method: http.MethodHead})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int64(len("you asked for /first")), resp.ContentLength)
+		assert.Empty(t, readResponseBody(t, resp))
+
+		// the next response would not be read if the body of the HEAD response was sent
+		resp, err = http.ReadResponse(clientReader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "you asked for /second", readResponseBody(t, resp))
+	})
+
+	t.Run("HEAD error response has no body", func(t *testing.T) {
+		panicHandler := func(w *response.Writer, req *request.Request) {
+			panic("gremio")
+		}
+		client := serveTestConn(t, New(WithHandler(panicHandler)))
+		clientReader := bufio.NewReader(client)
+
+		go func() {
+			_, _ = client.Write([]byte("HEAD / HTTP/1.1\r\nHost: localhost:42069\r\n\r\n"))
+		}()
+
+		resp, err := http.ReadResponse(clientReader, &http.Request{Method: http.MethodHead})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, int64(len("500 Internal Server Error\n")), resp.ContentLength)
+
+		_, err = clientReader.ReadByte()
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func echoPathHandler(w *response.Writer, req *request.Request) {
+	body := []byte(fmt.Sprintf("you asked for %s", req.URL.Path))
+	_ = w.WriteStatusLine(response.StatusOK)
+	_ = w.WriteHeaders(response.DefaultHeaders(len(body)))
+	_, _ = w.WriteBody(body)
+}
+
+// serveTestConn serves s on one side of a net.Pipe and returns the client side.
+func serveTestConn(t *testing.T, s *Server) net.Conn {
+	t.Helper()
+
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.handleConn(conn, "test")
+	}()
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("handleConn did not return after the client closed the connection")
+		}
+	})
+
+	return client
+}
+
+func readResponseBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+// waitConnState waits until all connections of s are in state.
+func waitConnState(t *testing.T, s *Server, state connState) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for _, st := range s.conns {
+			if st != state {
+				return false
+			}
+		}
+		return len(s.conns) > 0
+	}, time.Second, time.Millisecond)
+}
+
+// failingListener is a net.Listener whose Accept always fails with err.
+type failingListener struct {
+	err error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) { return nil, l.err }
+func (l *failingListener) Close() error              { return nil }
+func (l *failingListener) Addr() net.Addr            { return &net.TCPAddr{} }
+
+// fakeClock is time.Now shifted by an offset, it makes deadlines expire without waiting.
+type fakeClock struct {
+	offset atomic.Int64
+}
+
+// minuteAgo returns a clock one minute late, every deadline of one minute set with it is already expired.
+func minuteAgo() *fakeClock {
+	clock := &fakeClock{}
+	clock.shift(-time.Minute)
+	return clock
+}
+
+func (c *fakeClock) now() time.Time {
+	return time.Now().Add(time.Duration(c.offset.Load()))
+}
+
+func (c *fakeClock) shift(d time.Duration) {
+	c.offset.Add(int64(d))
+}
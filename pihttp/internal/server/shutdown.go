@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// connState tells Shutdown if a connection can be closed without cutting off a request.
+type connState int
+
+const (
+	// connStateIdle is a connection waiting for the next request.
+	connStateIdle connState = iota
+	// connStateActive is a connection with a request being answered.
+	connStateActive
+)
+
+// shutdownPollInterval is how often Shutdown checks if the active connections finished.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// aLongTimeAgo is a read deadline in the past, it unblocks a pending read.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// Shutdown gracefully stops the server. Shutdown stops accepting connections, closes the idle
+// connections and waits for the active connections to answer their in-flight request, those connections
+// are closed right after the response. The context of the in-flight requests is cancelled, so the handlers
+// can stop slow work and answer early.
+//
+// If ctx expires before all connections are closed, the remaining connections are closed
+// and Shutdown returns the ctx error.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.isClosed.Store(true)
+	s.cancelBaseCtx()
+
+	s.mu.Lock()
+	err := s.closeListener()
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if s.closeIdleConns() {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			for conn := range s.conns {
+				_ = conn.Close()
+			}
+			s.mu.Unlock()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeIdleConns unblocks the idle connections waiting for the next request, they are closed by handleConn.
+// closeIdleConns reports if there are no connections left.
+func (s *Server) closeIdleConns() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn, state := range s.conns {
+		if state == connStateIdle {
+			_ = conn.SetReadDeadline(aLongTimeAgo)
+		}
+	}
+
+	return len(s.conns) == 0
+}
+
+// closeListener closes the listener if the server is listening, s.mu must be held.
+func (s *Server) closeListener() error {
+	if s.tcpListener == nil {
+		return nil
+	}
+
+	err := s.tcpListener.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// trackConn adds conn to the connections Shutdown waits for.
+// trackConn reports false if the server is already closed and conn must not be served.
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.isClosed.Load() {
+		return false
+	}
+
+	s.conns[conn] = connStateActive
+
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+// setConnState sets the state of conn.
+// setConnState reports false when an idle connection must be closed because the server is closed.
+func (s *Server) setConnState(conn net.Conn, state connState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conns[conn] = state
+
+	return state != connStateIdle || !s.isClosed.Load()
+}